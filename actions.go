@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// entryAction is an action taken on an entry message, either from
+// one of its keyboard buttons or a reply to the message
+type entryAction struct {
+	bot       *tgbotapi.BotAPI
+	chatID    int64
+	secret    types.TelegramSecret
+	rss       *miniflux.Client
+	store     store.Store
+	messageID int     // The Telegram message the action was taken on
+	entryID   int64   // The Miniflux entry the message is for
+	entryIDs  []int64 // All the entries the action applies to, for token buttons
+	param     string  // Extra parameter some actions take, like a snooze option
+}
+
+// removeMessage deletes the message, leaving an Undo stub in its place for entry messages.
+// The message stays tracked if Telegram fails to delete it, unless it's already gone.
+func (a entryAction) removeMessage(markedRead bool) error {
+	if window := undoWindow(); window > 0 {
+		err := stubDeletedMessage(a.bot, a.chatID, a.secret, a.store, a.messageID, markedRead, time.Now().Add(window))
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.Error("Failed leaving undo message, deleting instead", "error", err)
+		}
+	}
+	if _, err := a.bot.DeleteMessage(tgbotapi.NewDeleteMessage(a.chatID, a.messageID)); err != nil {
+		if !isMessageGone(err) {
+			return err
+		}
+		slog.Info("Message is already gone from Telegram", "message", a.messageID)
+	}
+	// Deleting a message unpins it, so the pin goes with it
+	if err := a.store.DeletePin(a.entryID); err != nil {
+		return err
+	}
+	return a.store.DeleteEntryByTelegramID(a.messageID)
+}
+
+// run performs the action and returns the text to show the user.
+// The text describes the failure if an error is returned.
+func (a entryAction) run(action string) (string, error) {
+	switch action {
+	case markRead:
+		if err := a.rss.UpdateEntries([]int64{a.entryID}, "read"); err != nil {
+			return "Error marking entry as read", err
+		}
+		go updateKeyboard(a.bot, a.chatID, a.secret, a.rss, a.store, a.messageID, a.entryID)
+		go a.store.UpdateEntryTime(a.entryID, time.Now())
+		return "Marked entry as read", nil
+	case markUnread:
+		if err := a.rss.UpdateEntries([]int64{a.entryID}, "unread"); err != nil {
+			return "Error marking entry as unread", err
+		}
+		go updateKeyboard(a.bot, a.chatID, a.secret, a.rss, a.store, a.messageID, a.entryID)
+		go a.store.UpdateEntryTime(a.entryID, time.Now())
+		return "Marked entry as unread", nil
+	case deleteAndMark:
+		if err := a.rss.UpdateEntries([]int64{a.entryID}, "read"); err != nil {
+			return "Error marking entry as read", err
+		}
+		if err := a.removeMessage(true); err != nil {
+			return "Marked as read but couldn't delete message", err
+		}
+		return "Deleted message & marked as read", nil
+	case deleteMessage:
+		if err := a.removeMessage(false); err != nil {
+			return "Error deleting message", err
+		}
+		return "Deleted message", nil
+	case undoDelete:
+		if err := restoreDeletedMessage(a.bot, a.chatID, a.secret, a.rss, a.store, a.messageID); errors.Is(err, errUndoExpired) {
+			return "Too late to undo", err
+		} else if err != nil {
+			return "Error restoring message", err
+		}
+		return "Restored message", nil
+	case star:
+		if err := a.rss.ToggleBookmark(a.entryID); err != nil {
+			return "Error updating Miniflux entry", err
+		}
+		go updateKeyboard(a.bot, a.chatID, a.secret, a.rss, a.store, a.messageID, a.entryID)
+		go a.store.UpdateEntryTime(a.entryID, time.Now())
+		return "Updated entry", nil
+	case readHere:
+		if err := sendArticle(a.bot, a.chatID, a.secret, a.rss, a.messageID, a.entryID); err != nil {
+			return "Error fetching article", err
+		}
+		return "", nil
+	case readPage:
+		page, err := strconv.Atoi(a.param)
+		if err != nil {
+			return "Error loading page", err
+		}
+		if err := showArticlePage(a.bot, a.chatID, a.secret, a.rss, a.messageID, a.entryID, page); err != nil {
+			return "Error loading page", err
+		}
+		return "", nil
+	case telegraphPublish:
+		if _, err := publishInstantView(a.rss, a.store, a.entryID); err != nil {
+			return "Error publishing Instant View", err
+		}
+		go updateKeyboard(a.bot, a.chatID, a.secret, a.rss, a.store, a.messageID, a.entryID)
+		return "Published Instant View", nil
+	case later:
+		if err := showLaterOptions(a.bot, a.chatID, a.secret, a.messageID, a.entryID); err != nil {
+			return "Error showing snooze options", err
+		}
+		return "", nil
+	case snooze:
+		until, err := snoozeUntil(a.param, time.Now())
+		if err != nil {
+			return "Error snoozing entry", err
+		}
+		if err := snoozeEntry(a.bot, a.chatID, a.store, a.messageID, a.entryID, until); err != nil {
+			return "Error snoozing entry", err
+		}
+		return "Snoozed until " + until.Format("Mon 15:04"), nil
+	case cancelLater:
+		go updateKeyboard(a.bot, a.chatID, a.secret, a.rss, a.store, a.messageID, a.entryID)
+		return "", nil
+	case mute:
+		if err := showMuteOptions(a.bot, a.chatID, a.secret, a.messageID, a.entryID); err != nil {
+			return "Error showing mute options", err
+		}
+		return "", nil
+	case muteFeed:
+		until, err := muteUntil(a.param, time.Now())
+		if err != nil {
+			return "Error muting feed", err
+		}
+		mutedFeed, err := muteEntryFeed(a.rss, a.store, a.entryID, until)
+		if err != nil {
+			return "Error muting feed", err
+		}
+		go updateKeyboard(a.bot, a.chatID, a.secret, a.rss, a.store, a.messageID, a.entryID)
+		return describeMute(mutedFeed), nil
+	case unmuteFeed:
+		// The ID is the feed's for unmute buttons on the /muted message
+		if err := unmute(a.bot, a.chatID, a.secret, a.store, a.messageID, a.entryID); err != nil {
+			return "Error unmuting feed", err
+		}
+		return "Unmuted feed", nil
+	case selectEntry:
+		selected, err := toggleSelection(a.bot, a.chatID, a.secret, a.store, a.messageID, a.entryID)
+		go updateKeyboard(a.bot, a.chatID, a.secret, a.rss, a.store, a.messageID, a.entryID)
+		if err != nil {
+			return "Error updating selection", err
+		}
+		if selected {
+			return "Selected entry", nil
+		}
+		return "Unselected entry", nil
+	case bulkRead:
+		if err := a.rss.UpdateEntries(a.entryIDs, "read"); err != nil {
+			return "Error marking entries as read", err
+		}
+		if err := clearSelected(a.bot, a.chatID, a.secret, a.rss, a.store, a.entryIDs, true); err != nil {
+			return "Error updating selection", err
+		}
+		return fmt.Sprintf("Marked %d entries as read", len(a.entryIDs)), nil
+	case bulkStar:
+		if err := starEntries(a.rss, a.entryIDs); err != nil {
+			return "Error starring entries", err
+		}
+		if err := clearSelected(a.bot, a.chatID, a.secret, a.rss, a.store, a.entryIDs, true); err != nil {
+			return "Error updating selection", err
+		}
+		return fmt.Sprintf("Starred %d entries", len(a.entryIDs)), nil
+	case bulkDelete:
+		// Entries whose messages couldn't be deleted stay selected so they can be tried again
+		deleted, deleteErr := a.deleteSelectedMessages(a.entryIDs)
+		if err := clearSelected(a.bot, a.chatID, a.secret, a.rss, a.store, deleted, false); err != nil {
+			return "Error updating selection", err
+		}
+		if deleteErr != nil {
+			return fmt.Sprintf("Deleted %d of %d messages", len(deleted), len(a.entryIDs)), deleteErr
+		}
+		return fmt.Sprintf("Deleted %d messages", len(deleted)), nil
+	case bulkClear:
+		if err := clearSelected(a.bot, a.chatID, a.secret, a.rss, a.store, a.entryIDs, true); err != nil {
+			return "Error updating selection", err
+		}
+		return "Cleared selection", nil
+	case browseCategory:
+		// The ID is the category's for buttons on the dashboard
+		sent, err := sendCategoryEntries(a.bot, a.chatID, a.secret, a.rss, a.store, a.entryID)
+		if err != nil {
+			return "Error sending entries", err
+		}
+		if sent == 0 {
+			return "No more unread entries in this category", nil
+		}
+		return fmt.Sprintf("Sent %d entries", sent), nil
+	case refreshDashboard:
+		if err := updateDashboard(a.bot, a.chatID, a.secret, a.rss, a.store); err != nil {
+			return "Error refreshing dashboard", err
+		}
+		return "Dashboard refreshed", nil
+	case showNotes:
+		if err := sendEntryNotes(a.bot, a.chatID, a.store, a.messageID, a.entryID); err != nil {
+			return "Error loading notes", err
+		}
+		return "", nil
+	case noop:
+		return "", nil
+	}
+	return "Unknown action", fmt.Errorf("unknown action %q", action)
+}
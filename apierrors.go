@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	miniflux "miniflux.app/client"
+)
+
+// Telegram only describes errors in text, so these are matched on the description
+var (
+	telegramMessageGoneErrors = []string{
+		"message to delete not found",
+		"message to edit not found",
+		"message to unpin not found",
+		"MESSAGE_ID_INVALID",
+	}
+	telegramNotModifiedErrors = []string{
+		"message is not modified",
+	}
+	telegramNoTextErrors = []string{
+		"there is no text in the message to edit",
+	}
+)
+
+func errorContains(err error, descriptions []string) bool {
+	if err == nil {
+		return false
+	}
+	for _, description := range descriptions {
+		if strings.Contains(err.Error(), description) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMessageGone checks whether a Telegram error is because the message no longer exists,
+// usually because it was deleted by hand
+func isMessageGone(err error) bool {
+	return errorContains(err, telegramMessageGoneErrors)
+}
+
+// isNotModified checks whether a Telegram edit failed because the message already looks like that
+func isNotModified(err error) bool {
+	return errorContains(err, telegramNotModifiedErrors)
+}
+
+// isNoText checks whether a Telegram text edit failed because the message has media instead
+func isNoText(err error) bool {
+	return errorContains(err, telegramNoTextErrors)
+}
+
+// isEntryGone checks whether a Miniflux error is because the entry no longer exists,
+// such as when it's been flushed or its feed deleted
+func isEntryGone(err error) bool {
+	return errors.Is(err, miniflux.ErrNotFound)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	miniflux "miniflux.app/client"
+)
+
+func TestClassifyErrors(t *testing.T) {
+	var tests = []struct {
+		err         error
+		gone        bool
+		notModified bool
+		entryGone   bool
+		explanation string
+	}{
+		{tgbotapi.Error{Message: "Bad Request: message to delete not found"}, true, false, false, "Deleted messages are gone"},
+		{tgbotapi.Error{Message: "Bad Request: message to edit not found"}, true, false, false, "Messages that can't be found to edit are gone"},
+		{tgbotapi.Error{Message: "Bad Request: message is not modified: specified new message content and reply markup are exactly the same"}, false, true, false, "Edits that change nothing"},
+		{tgbotapi.Error{Message: "Bad Request: message can't be deleted"}, false, false, false, "Messages too old to delete aren't gone"},
+		{fmt.Errorf("fetching entry: %w", miniflux.ErrNotFound), false, false, true, "Entries Miniflux can't find are gone"},
+		{errors.New("connection refused"), false, false, false, "Other errors aren't classified"},
+		{nil, false, false, false, "No error"},
+	}
+
+	for _, tt := range tests {
+		if gone := isMessageGone(tt.err); gone != tt.gone {
+			t.Errorf("%s: isMessageGone got %v, want %v", tt.explanation, gone, tt.gone)
+		}
+		if notModified := isNotModified(tt.err); notModified != tt.notModified {
+			t.Errorf("%s: isNotModified got %v, want %v", tt.explanation, notModified, tt.notModified)
+		}
+		if entryGone := isEntryGone(tt.err); entryGone != tt.entryGone {
+			t.Errorf("%s: isEntryGone got %v, want %v", tt.explanation, entryGone, tt.entryGone)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package article
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Tags Telegram's HTML parse mode understands, mapped from their HTML equivalents
+var inlineTags = map[string]string{
+	"b":      "b",
+	"strong": "b",
+	"i":      "i",
+	"em":     "i",
+	"u":      "u",
+	"ins":    "u",
+	"s":      "s",
+	"strike": "s",
+	"del":    "s",
+	"code":   "code",
+	"a":      "a",
+}
+
+// Tags which start a new block of text
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true,
+	"footer": true, "aside": true, "figure": true, "figcaption": true, "ul": true,
+	"ol": true, "li": true, "table": true, "tr": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "dl": true,
+	"dt": true, "dd": true, "pre": true, "blockquote": true,
+}
+
+// Tags whose content should never be shown
+var skippedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "iframe": true,
+	"svg": true, "head": true, "template": true, "form": true,
+}
+
+type converter struct {
+	blocks  []string
+	current strings.Builder
+	open    []string // Telegram tags currently open in the block
+	openRaw []string // Opening tag for each entry in open, used to reopen them
+	skip    int      // Depth inside skipped tags
+	pre     int      // Depth inside pre tags
+	heading int      // Depth inside heading tags
+}
+
+// ToTelegramHTML converts article HTML into a list of blocks (paragraphs,
+// list items, headings etc) that only use the subset of HTML Telegram supports.
+// Each block has balanced tags so blocks can be split across messages.
+func ToTelegramHTML(content string) []string {
+	c := &converter{}
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == nethtml.ErrorToken {
+			// Either the end of the document or invalid HTML, keep what we've got so far
+			break
+		}
+		token := tokenizer.Token()
+		switch tokenType {
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			c.startTag(token, tokenType == nethtml.SelfClosingTagToken)
+		case nethtml.EndTagToken:
+			c.endTag(token)
+		case nethtml.TextToken:
+			c.text(token.Data)
+		}
+	}
+	c.flush()
+	return c.blocks
+}
+
+func (c *converter) startTag(token nethtml.Token, selfClosing bool) {
+	name := token.Data
+	if skippedTags[name] {
+		if !selfClosing {
+			c.skip++
+		}
+		return
+	}
+	if c.skip > 0 {
+		return
+	}
+
+	switch {
+	case name == "br":
+		c.current.WriteString("\n")
+	case name == "img":
+		if alt := attr(token, "alt"); alt != "" {
+			c.writeText("[" + alt + "]")
+		}
+	case blockTags[name]:
+		c.flush()
+		switch name {
+		case "li":
+			c.current.WriteString("• ")
+		case "pre":
+			c.pre++
+			c.openTag("pre", "<pre>")
+		case "blockquote":
+			c.openTag("blockquote", "<blockquote>")
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			c.heading++
+			c.openTag("b", "<b>")
+		}
+	case inlineTags[name] != "":
+		tag := inlineTags[name]
+		if tag == "a" {
+			href := attr(token, "href")
+			if !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "https://") {
+				// Relative or script links can't be followed from Telegram, keep the text only
+				c.open = append(c.open, "")
+				c.openRaw = append(c.openRaw, "")
+				return
+			}
+			c.openTag("a", `<a href="`+html.EscapeString(href)+`">`)
+			return
+		}
+		c.openTag(tag, "<"+tag+">")
+	}
+}
+
+func (c *converter) endTag(token nethtml.Token) {
+	name := token.Data
+	if skippedTags[name] {
+		if c.skip > 0 {
+			c.skip--
+		}
+		return
+	}
+	if c.skip > 0 {
+		return
+	}
+
+	switch {
+	case blockTags[name]:
+		switch name {
+		case "pre":
+			if c.pre > 0 {
+				c.pre--
+			}
+			c.closeTag("pre")
+		case "blockquote":
+			c.closeTag("blockquote")
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			if c.heading > 0 {
+				c.heading--
+				c.closeTag("b")
+			}
+		}
+		c.flush()
+	case inlineTags[name] != "":
+		tag := inlineTags[name]
+		if tag == "a" {
+			// Links without a usable href were pushed as empty tags
+			for i := len(c.open) - 1; i >= 0; i-- {
+				if c.open[i] == "a" || c.open[i] == "" {
+					c.closeTag(c.open[i])
+					return
+				}
+			}
+			return
+		}
+		c.closeTag(tag)
+	}
+}
+
+func (c *converter) text(data string) {
+	if c.skip > 0 {
+		return
+	}
+	if c.pre > 0 {
+		c.current.WriteString(html.EscapeString(data))
+		return
+	}
+	// Collapse whitespace the way a browser would
+	collapsed := strings.Join(strings.Fields(data), " ")
+	if collapsed == "" {
+		if data != "" && c.needsSpace() {
+			c.current.WriteString(" ")
+		}
+		return
+	}
+	if startsWithSpace(data) && c.needsSpace() {
+		collapsed = " " + collapsed
+	}
+	if endsWithSpace(data) {
+		collapsed += " "
+	}
+	c.writeText(collapsed)
+}
+
+// needsSpace checks whether whitespace before the next piece of text would be visible
+func (c *converter) needsSpace() bool {
+	current := stripTags(c.current.String())
+	return current != "" && !endsWithSpace(c.current.String())
+}
+
+func (c *converter) writeText(text string) {
+	c.current.WriteString(html.EscapeString(text))
+}
+
+func (c *converter) openTag(tag, raw string) {
+	c.open = append(c.open, tag)
+	c.openRaw = append(c.openRaw, raw)
+	c.current.WriteString(raw)
+}
+
+// closeTag closes the most recently opened tag matching tag, along with any tags opened after it
+func (c *converter) closeTag(tag string) {
+	for i := len(c.open) - 1; i >= 0; i-- {
+		if c.open[i] != tag {
+			continue
+		}
+		for j := len(c.open) - 1; j >= i; j-- {
+			if c.open[j] != "" {
+				c.current.WriteString("</" + c.open[j] + ">")
+			}
+		}
+		c.open = c.open[:i]
+		c.openRaw = c.openRaw[:i]
+		return
+	}
+}
+
+// flush finishes the current block, closing any open tags and reopening them in the next block
+func (c *converter) flush() {
+	for i := len(c.open) - 1; i >= 0; i-- {
+		if c.open[i] != "" {
+			c.current.WriteString("</" + c.open[i] + ">")
+		}
+	}
+	block := strings.TrimSpace(c.current.String())
+	if stripTags(block) != "" {
+		c.blocks = append(c.blocks, block)
+	}
+	c.current.Reset()
+	for _, raw := range c.openRaw {
+		c.current.WriteString(raw)
+	}
+}
+
+// Pages converts article HTML to Telegram-safe HTML and splits it into pages no longer than limit bytes
+func Pages(content string, limit int) []string {
+	return Paginate(ToTelegramHTML(content), limit)
+}
+
+// Paginate packs blocks into pages no longer than limit bytes.
+// Blocks longer than limit are split as plain text.
+func Paginate(blocks []string, limit int) []string {
+	pages := make([]string, 0)
+	var page strings.Builder
+	add := func(block string) {
+		if page.Len() > 0 && page.Len()+2+len(block) > limit {
+			pages = append(pages, page.String())
+			page.Reset()
+		}
+		if page.Len() > 0 {
+			page.WriteString("\n\n")
+		}
+		page.WriteString(block)
+	}
+
+	for _, block := range blocks {
+		if len(block) <= limit {
+			add(block)
+			continue
+		}
+		for _, chunk := range splitText(html.UnescapeString(stripTags(block)), limit) {
+			add(chunk)
+		}
+	}
+	if page.Len() > 0 {
+		pages = append(pages, page.String())
+	}
+	return pages
+}
+
+// splitText splits plain text into escaped chunks no longer than limit bytes, preferring to break on spaces
+func splitText(text string, limit int) []string {
+	chunks := make([]string, 0)
+	for text != "" {
+		// Find the longest prefix that still fits once escaped
+		end := 0
+		lastSpace := -1
+		size := 0
+		for end < len(text) {
+			r, width := utf8.DecodeRuneInString(text[end:])
+			escaped := len(html.EscapeString(string(r)))
+			if size+escaped > limit {
+				break
+			}
+			if r == ' ' || r == '\n' {
+				lastSpace = end
+			}
+			size += escaped
+			end += width
+		}
+		if end < len(text) && lastSpace > 0 {
+			end = lastSpace
+		}
+		if end == 0 {
+			// Limit is smaller than a single character, nothing sensible we can do
+			break
+		}
+		chunks = append(chunks, html.EscapeString(strings.TrimSpace(text[:end])))
+		text = strings.TrimLeft(text[end:], " \n")
+	}
+	return chunks
+}
+
+// stripTags removes all tags from Telegram HTML, leaving escaped text
+func stripTags(s string) string {
+	var out strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			out.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func attr(token nethtml.Token, key string) string {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func startsWithSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == ' ' || r == '\n' || r == '\t' || r == '\r'
+}
+
+func endsWithSpace(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r == ' ' || r == '\n' || r == '\t' || r == '\r'
+}
@@ -0,0 +1,87 @@
+package article
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToTelegramHTML(t *testing.T) {
+	var tests = []struct {
+		explanation string
+		input       string
+		expected    []string
+	}{
+		{
+			"Paragraphs become separate blocks",
+			"<p>First</p><p>Second</p>",
+			[]string{"First", "Second"},
+		}, {
+			"Supported formatting is mapped to Telegram tags",
+			"<p><strong>Bold</strong> and <em>italic</em></p>",
+			[]string{"<b>Bold</b> and <i>italic</i>"},
+		}, {
+			"Unsupported tags are dropped but their text kept",
+			`<p><span class="x">Hello</span> <mark>world</mark></p>`,
+			[]string{"Hello world"},
+		}, {
+			"Scripts and styles are removed",
+			"<style>p{}</style><p>Text</p><script>alert(1)</script>",
+			[]string{"Text"},
+		}, {
+			"Text is escaped",
+			"<p>1 &lt; 2 &amp; 3 > 2</p>",
+			[]string{"1 &lt; 2 &amp; 3 &gt; 2"},
+		}, {
+			"Relative links keep only their text",
+			`<p><a href="/about">About</a> <a href="https://example.com">Example</a></p>`,
+			[]string{`About <a href="https://example.com">Example</a>`},
+		}, {
+			"Headings are bold",
+			"<h2>Title</h2><p>Body</p>",
+			[]string{"<b>Title</b>", "Body"},
+		}, {
+			"Formatting across blocks stays balanced",
+			"<b><p>One</p><p>Two</p></b>",
+			[]string{"<b>One</b>", "<b>Two</b>"},
+		}, {
+			"List items are bulleted",
+			"<ul><li>One</li><li>Two</li></ul>",
+			[]string{"• One", "• Two"},
+		},
+	}
+
+	for _, tt := range tests {
+		blocks := ToTelegramHTML(tt.input)
+		if strings.Join(blocks, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: input [%s], got %q, want %q", tt.explanation, tt.input, blocks, tt.expected)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	blocks := []string{strings.Repeat("a", 40), strings.Repeat("b", 40), strings.Repeat("c", 40)}
+	pages := Paginate(blocks, 100)
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d: %q", len(pages), pages)
+	}
+	for _, page := range pages {
+		if len(page) > 100 {
+			t.Errorf("page longer than limit: %d", len(page))
+		}
+	}
+
+	// A single block larger than the limit is split on spaces
+	long := strings.TrimSpace(strings.Repeat("word & ", 50))
+	pages = Paginate([]string{"<b>" + long + "</b>"}, 60)
+	for _, page := range pages {
+		if len(page) > 60 {
+			t.Errorf("page longer than limit: %d", len(page))
+		}
+		if strings.Contains(page, "<b>") || strings.HasPrefix(page, " ") {
+			t.Errorf("split page should be plain text without leading space: %q", page)
+		}
+	}
+	if len(pages) < 2 {
+		t.Errorf("expected long block to be split, got %d pages", len(pages))
+	}
+}
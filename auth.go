@@ -0,0 +1,19 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// isAuthorisedUser checks whether a Telegram user is allowed to act on our entries.
+// Users are authorised if they are the chat we send entries to or match the
+// configured allowed username. Every update that reads or changes our state,
+// whether a command, button, reply, upload or inline query, is checked with this.
+func isAuthorisedUser(user *tgbotapi.User, chatID int64, allowedUsername string) bool {
+	if user == nil {
+		return false
+	}
+	if int64(user.ID) == chatID {
+		return true
+	}
+	return allowedUsername != "" && user.UserName == allowedUsername
+}
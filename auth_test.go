@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestIsAuthorisedUser(t *testing.T) {
+	const chatID = 1
+	owner := &tgbotapi.User{ID: chatID, UserName: "owner"}
+	allowed := &tgbotapi.User{ID: 2, UserName: "friend"}
+	stranger := &tgbotapi.User{ID: 3, UserName: "stranger"}
+
+	var tests = []struct {
+		user        *tgbotapi.User
+		allowed     string
+		expected    bool
+		explanation string
+	}{
+		{owner, "", true, "The chat's owner is always authorised"},
+		{owner, "friend", true, "The chat's owner is authorised alongside an allowed username"},
+		{allowed, "friend", true, "The allowed username is authorised"},
+		{stranger, "friend", false, "Other users aren't authorised"},
+		{stranger, "", false, "Without an allowed username only the owner is authorised"},
+		{&tgbotapi.User{ID: 4}, "", false, "Users without a username don't match an empty allowed username"},
+		{nil, "friend", false, "Updates without a sender aren't authorised"},
+	}
+
+	for _, tt := range tests {
+		if got := isAuthorisedUser(tt.user, chatID, tt.allowed); got != tt.expected {
+			t.Errorf("%s: got %v, want %v", tt.explanation, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"go.jloh.dev/miniflux-telegram-bot/types"
+)
+
+// MaxLength is the most bytes Telegram allows in callback data
+const MaxLength = 64
+
+// Length of the truncated signature, 12 base64 characters is 72 bits
+const signatureLength = 12
+
+const separator = ":"
+
+var (
+	ErrMalformed        = errors.New("callback: malformed data")
+	ErrInvalidSignature = errors.New("callback: invalid signature")
+)
+
+// Data is the information carried by an inline keyboard button
+type Data struct {
+	Action  string // Short action code
+	EntryID int64  // Miniflux entry ID, 0 if the action isn't for an entry
+	Param   string // Optional extra parameter, e.g. a page number
+}
+
+// Encode packs data into the compact signed form sent with a button:
+// action:entryID[:param]:signature, with the entry ID in base 36
+func Encode(secret types.TelegramSecret, data Data) string {
+	payload := data.Action + separator
+	if data.EntryID != 0 {
+		payload += strconv.FormatInt(data.EntryID, 36)
+	}
+	if data.Param != "" {
+		payload += separator + data.Param
+	}
+	return payload + separator + sign(secret, payload)
+}
+
+// Decode verifies and unpacks callback data created by Encode
+func Decode(secret types.TelegramSecret, raw string) (Data, error) {
+	var data Data
+
+	split := strings.LastIndex(raw, separator)
+	if split == -1 {
+		return data, ErrMalformed
+	}
+	payload, signature := raw[:split], raw[split+1:]
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, payload))) {
+		return data, ErrInvalidSignature
+	}
+
+	parts := strings.SplitN(payload, separator, 3)
+	if len(parts) < 2 || parts[0] == "" {
+		return data, ErrMalformed
+	}
+	data.Action = parts[0]
+	if parts[1] != "" {
+		entryID, err := strconv.ParseInt(parts[1], 36, 64)
+		if err != nil {
+			return data, ErrMalformed
+		}
+		data.EntryID = entryID
+	}
+	if len(parts) == 3 {
+		data.Param = parts[2]
+	}
+	return data, nil
+}
+
+// DecodeLegacy unpacks the secret:action:entryID[:param] data sent by older
+// versions, so keyboards already in the chat keep working after an upgrade
+func DecodeLegacy(secret types.TelegramSecret, raw string) (Data, error) {
+	var data Data
+
+	parts := strings.SplitN(raw, separator, 4)
+	if len(parts) < 2 || parts[1] == "" {
+		return data, ErrMalformed
+	}
+	if !hmac.Equal([]byte(parts[0]), []byte(secret)) {
+		return data, ErrInvalidSignature
+	}
+	data.Action = parts[1]
+	if len(parts) >= 3 && parts[2] != "" {
+		entryID, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return data, ErrMalformed
+		}
+		data.EntryID = entryID
+	}
+	if len(parts) == 4 {
+		data.Param = parts[3]
+	}
+	return data, nil
+}
+
+// sign returns a truncated HMAC of the payload keyed with our secret
+func sign(secret types.TelegramSecret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:signatureLength]
+}
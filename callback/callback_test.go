@@ -0,0 +1,142 @@
+package callback
+
+import (
+	"strings"
+	"testing"
+
+	"go.jloh.dev/miniflux-telegram-bot/types"
+)
+
+func TestRoundTrip(t *testing.T) {
+	secret := types.TelegramSecret("a much longer secret than fifteen characters")
+	var tests = []struct {
+		explanation string
+		data        Data
+	}{
+		{
+			"Action without an entry",
+			Data{Action: "d"},
+		}, {
+			"Action with an entry",
+			Data{Action: "r", EntryID: 123456789},
+		}, {
+			"Action with an entry and parameter",
+			Data{Action: "p", EntryID: 9007199254740993, Param: "12"},
+		}, {
+			"Parameter containing the separator",
+			Data{Action: "x", EntryID: 1, Param: "-1001234567890:42"},
+		},
+	}
+
+	for _, tt := range tests {
+		raw := Encode(secret, tt.data)
+		if len(raw) > MaxLength {
+			t.Errorf("%s: encoded data is %d bytes, over the %d byte limit", tt.explanation, len(raw), MaxLength)
+		}
+		if strings.Contains(raw, string(secret)) {
+			t.Errorf("%s: encoded data contains the secret", tt.explanation)
+		}
+		data, err := Decode(secret, raw)
+		if err != nil {
+			t.Errorf("%s: unexpected error decoding %q: %v", tt.explanation, raw, err)
+		}
+		if data != tt.data {
+			t.Errorf("%s: got %+v, want %+v", tt.explanation, data, tt.data)
+		}
+	}
+}
+
+func TestDecodeRejectsInvalidData(t *testing.T) {
+	secret := types.TelegramSecret("correct horse battery staple")
+	valid := Encode(secret, Data{Action: "r", EntryID: 42})
+
+	var tests = []struct {
+		explanation string
+		raw         string
+		secret      types.TelegramSecret
+	}{
+		{
+			"Signed with a different secret",
+			valid,
+			"another secret entirely",
+		}, {
+			"Tampered entry ID",
+			strings.Replace(valid, "r:16", "r:17", 1),
+			secret,
+		}, {
+			"Tampered action",
+			"s" + valid[1:],
+			secret,
+		}, {
+			"Missing signature",
+			"r:16",
+			secret,
+		}, {
+			"Legacy plaintext format",
+			string(secret) + ":markRead:42",
+			secret,
+		}, {
+			"Empty data",
+			"",
+			secret,
+		},
+	}
+
+	for _, tt := range tests {
+		if _, err := Decode(tt.secret, tt.raw); err == nil {
+			t.Errorf("%s: expected %q to be rejected", tt.explanation, tt.raw)
+		}
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	secret := types.TelegramSecret("oohue2Oob3leyah")
+	var tests = []struct {
+		explanation string
+		raw         string
+		expected    Data
+		valid       bool
+	}{
+		{
+			"Action without an entry",
+			"oohue2Oob3leyah:deleteMessage",
+			Data{Action: "deleteMessage"},
+			true,
+		}, {
+			"Action with an entry",
+			"oohue2Oob3leyah:markRead:42",
+			Data{Action: "markRead", EntryID: 42},
+			true,
+		}, {
+			"Action with an entry and parameter",
+			"oohue2Oob3leyah:readPage:42:3",
+			Data{Action: "readPage", EntryID: 42, Param: "3"},
+			true,
+		}, {
+			"Wrong secret",
+			"anothersecret:markRead:42",
+			Data{},
+			false,
+		}, {
+			"Invalid entry ID",
+			"oohue2Oob3leyah:markRead:abc",
+			Data{},
+			false,
+		}, {
+			"Missing action",
+			"oohue2Oob3leyah",
+			Data{},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		data, err := DecodeLegacy(secret, tt.raw)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: input [%s], got error %v, want valid %v", tt.explanation, tt.raw, err, tt.valid)
+		}
+		if tt.valid && data != tt.expected {
+			t.Errorf("%s: got %+v, want %+v", tt.explanation, data, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/spf13/viper"
+	miniflux "miniflux.app/client"
+)
+
+// What happens to an entry's message once it's been read
+const (
+	cleanupDelete   string = "delete"   // Delete the message
+	cleanupCollapse string = "collapse" // Replace the message with a one line link
+	cleanupKeep     string = "keep"     // Leave the message alone
+)
+
+// What happens to an entry's message once the entry is gone from Miniflux
+const (
+	goneDelete string = "delete" // Delete the message
+	goneStub   string = "stub"   // Replace the message with a note saying the entry is gone
+)
+
+// cleanupGoneAction is what to do with messages for entries that are gone from Miniflux
+func cleanupGoneAction() string {
+	return viper.GetString("TELEGRAM_CLEANUP_GONE")
+}
+
+// What happens to a read entry's message just before Telegram stops letting us delete it
+const (
+	expiringStrip    string = "strip"    // Remove the message's keyboard
+	expiringCollapse string = "collapse" // Replace the message with a one line link
+	expiringKeep     string = "keep"     // Leave the message alone
+)
+
+// cleanupPolicy controls how the sync loop tidies up messages for read entries
+type cleanupPolicy struct {
+	Grace    time.Duration // How long an entry has to have been read before it's cleaned up
+	Read     string        // What to do with messages for read entries
+	Starred  string        // What to do with messages for read entries which are starred
+	Expiring string        // What to do with messages for read entries that are about to become undeletable
+}
+
+// builtinCleanupPolicy is used when nothing has been configured
+var builtinCleanupPolicy = cleanupPolicy{Grace: 2 * time.Hour, Read: cleanupDelete, Starred: cleanupDelete, Expiring: expiringCollapse}
+
+// parseCleanupAction checks a cleanup action is one we know about
+func parseCleanupAction(action string) (string, error) {
+	switch action {
+	case cleanupDelete, cleanupCollapse, cleanupKeep:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown cleanup action %q, expected delete, collapse or keep", action)
+}
+
+// parseExpiringAction checks an action for expiring messages is one we know about
+func parseExpiringAction(action string) (string, error) {
+	switch action {
+	case expiringStrip, expiringCollapse, expiringKeep:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown expiring action %q, expected strip, collapse or keep", action)
+}
+
+// loadCleanupPolicy reads a cleanup policy from settings with the given suffix, using
+// fallback for anything that isn't set. Starred entries follow the read action by default.
+func loadCleanupPolicy(suffix string, fallback cleanupPolicy) (cleanupPolicy, error) {
+	policy := fallback
+	if viper.IsSet("TELEGRAM_CLEANUP_GRACE" + suffix) {
+		grace := viper.GetInt64("TELEGRAM_CLEANUP_GRACE" + suffix)
+		if grace < 0 {
+			return policy, fmt.Errorf("TELEGRAM_CLEANUP_GRACE%s can't be negative", suffix)
+		}
+		policy.Grace = time.Duration(grace) * time.Minute
+	}
+	if read := viper.GetString("TELEGRAM_CLEANUP_READ" + suffix); read != "" {
+		action, err := parseCleanupAction(read)
+		if err != nil {
+			return policy, err
+		}
+		policy.Read = action
+		policy.Starred = action
+	}
+	if starred := viper.GetString("TELEGRAM_CLEANUP_STARRED" + suffix); starred != "" {
+		action, err := parseCleanupAction(starred)
+		if err != nil {
+			return policy, err
+		}
+		policy.Starred = action
+	}
+	if expiring := viper.GetString("TELEGRAM_CLEANUP_EXPIRING" + suffix); expiring != "" {
+		action, err := parseExpiringAction(expiring)
+		if err != nil {
+			return policy, err
+		}
+		policy.Expiring = action
+	}
+	return policy, nil
+}
+
+// defaultCleanupPolicy is the policy used for entries without a category policy
+func defaultCleanupPolicy() (cleanupPolicy, error) {
+	return loadCleanupPolicy("", builtinCleanupPolicy)
+}
+
+// cleanupPolicyFor returns the cleanup policy for a category, falling back to the global policy
+func cleanupPolicyFor(categoryID int64) cleanupPolicy {
+	global, err := defaultCleanupPolicy()
+	if err != nil {
+		slog.Error("Invalid cleanup policy, using default", "error", err)
+		global = builtinCleanupPolicy
+	}
+
+	policy, err := loadCleanupPolicy(fmt.Sprintf("_%d", categoryID), global)
+	if err != nil {
+		slog.Error("Invalid cleanup policy for category, using global policy", "error", err, "category", categoryID)
+		return global
+	}
+	return policy
+}
+
+// cleanupInterval is how long the sync loop waits between runs
+func cleanupInterval() time.Duration {
+	return time.Duration(viper.GetInt64("TELEGRAM_CLEANUP_INTERVAL")) * time.Minute
+}
+
+// expiringMargin is how long before Telegram's delete window closes that messages get their
+// final edit. It covers two runs of the sync loop so a slow run doesn't miss the window.
+func expiringMargin() time.Duration {
+	return max(2*cleanupInterval(), time.Hour)
+}
+
+// stripKeyboard removes a message's keyboard
+func stripKeyboard(bot *tgbotapi.BotAPI, chatID int64, messageID int) error {
+	// An empty keyboard, rather than none at all, is what tells Telegram to remove it
+	empty := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: make([][]tgbotapi.InlineKeyboardButton, 0)}
+	_, err := bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, empty))
+	return err
+}
+
+// validateCleanupConfig checks the global cleanup settings so mistakes are caught on startup
+func validateCleanupConfig() error {
+	if cleanupInterval() <= 0 {
+		return fmt.Errorf("TELEGRAM_CLEANUP_INTERVAL must be at least 1 minute")
+	}
+	if gone := cleanupGoneAction(); gone != goneDelete && gone != goneStub {
+		return fmt.Errorf("unknown TELEGRAM_CLEANUP_GONE action %q, expected delete or stub", gone)
+	}
+	_, err := defaultCleanupPolicy()
+	return err
+}
+
+// collapseMessage replaces an entry message with a one line link to the entry
+func collapseMessage(bot *tgbotapi.BotAPI, chatID int64, messageID int, entry *miniflux.Entry) error {
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf(`✓ <a href="%s">%s</a>`,
+		html.EscapeString(entry.URL),
+		html.EscapeString(entry.Title),
+	))
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	_, err := bot.Send(msg)
+	return err
+}
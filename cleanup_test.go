@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestCleanupPolicyFor(t *testing.T) {
+	settings := map[string]any{
+		"TELEGRAM_CLEANUP_GRACE":      60,
+		"TELEGRAM_CLEANUP_READ":       "collapse",
+		"TELEGRAM_CLEANUP_READ_5":     "delete",
+		"TELEGRAM_CLEANUP_STARRED_5":  "keep",
+		"TELEGRAM_CLEANUP_GRACE_6":    0,
+		"TELEGRAM_CLEANUP_EXPIRING_6": "strip",
+		"TELEGRAM_CLEANUP_READ_7":     "archive",
+	}
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+	defer func() {
+		for key := range settings {
+			viper.Set(key, nil)
+		}
+	}()
+
+	var tests = []struct {
+		categoryID  int64
+		expected    cleanupPolicy
+		explanation string
+	}{
+		{1, cleanupPolicy{Grace: time.Hour, Read: cleanupCollapse, Starred: cleanupCollapse, Expiring: expiringCollapse}, "Categories without a policy use the global one"},
+		{5, cleanupPolicy{Grace: time.Hour, Read: cleanupDelete, Starred: cleanupKeep, Expiring: expiringCollapse}, "Category actions override the global ones"},
+		{6, cleanupPolicy{Grace: 0, Read: cleanupCollapse, Starred: cleanupCollapse, Expiring: expiringStrip}, "A zero grace period can be set"},
+		{7, cleanupPolicy{Grace: time.Hour, Read: cleanupCollapse, Starred: cleanupCollapse, Expiring: expiringCollapse}, "Invalid category policies fall back to the global one"},
+	}
+
+	for _, tt := range tests {
+		if policy := cleanupPolicyFor(tt.categoryID); policy != tt.expected {
+			t.Errorf("%s: got %+v, want %+v", tt.explanation, policy, tt.expected)
+		}
+	}
+}
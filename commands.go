@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// permission is the level of access a command requires
+type permission int
+
+const (
+	permissionAnyone     permission = iota // Anyone who can message the bot
+	permissionAuthorised                   // Only users passing isAuthorisedUser
+)
+
+// commandContext contains everything a command handler needs to respond
+type commandContext struct {
+	bot     *tgbotapi.BotAPI
+	chatID  int64
+	secret  types.TelegramSecret
+	rss     *miniflux.Client
+	store   store.Store
+	message *tgbotapi.Message
+	args    string
+}
+
+// reply sends a plain text message back to the chat the command came from
+func (ctx commandContext) reply(text string) error {
+	return ctx.replyWithKeyboard(text, nil)
+}
+
+// replyWithKeyboard is reply with buttons under the message. Buttons only work in
+// the chat we send entries to, so they're left off replies anywhere else.
+func (ctx commandContext) replyWithKeyboard(text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	msg := tgbotapi.NewMessage(ctx.message.Chat.ID, text)
+	if keyboard != nil && isInEntryChat(ctx.message, ctx.chatID) {
+		msg.ReplyMarkup = keyboard
+	}
+	_, err := ctx.bot.Send(msg)
+	return err
+}
+
+// command describes a bot command and how to handle it
+type command struct {
+	Name        string                         // Command name without the leading slash
+	Description string                         // Short description shown in /help and the Telegram menu
+	Args        string                         // Argument syntax, e.g. "<feed ID>"
+	Permission  permission                     // Who is allowed to run the command
+	Handler     func(ctx commandContext) error // Function run when the command is received
+}
+
+// usage returns how the command should be invoked
+func (c command) usage() string {
+	if c.Args == "" {
+		return "/" + c.Name
+	}
+	return "/" + c.Name + " " + c.Args
+}
+
+// commandRegistry contains all the commands the bot responds to
+type commandRegistry struct {
+	commands map[string]command
+	order    []string
+}
+
+func newCommandRegistry() *commandRegistry {
+	registry := &commandRegistry{
+		commands: make(map[string]command),
+	}
+	registry.register(command{
+		Name:        "start",
+		Description: "Check the bot is online",
+		Permission:  permissionAuthorised,
+		Handler:     startCommand,
+	})
+	registry.register(command{
+		Name:        "help",
+		Description: "List available commands",
+		Permission:  permissionAnyone,
+		Handler: func(ctx commandContext) error {
+			return ctx.reply(registry.helpText())
+		},
+	})
+	registry.register(command{
+		Name:        "randomunread",
+		Description: "Send a random unread entry",
+		Permission:  permissionAuthorised,
+		Handler:     randomUnreadCommand,
+	})
+	registry.register(command{
+		Name:        "export",
+		Description: "Export your subscriptions as OPML",
+		Permission:  permissionAuthorised,
+		Handler:     exportCommand,
+	})
+	registry.register(command{
+		Name:        "notes",
+		Description: "List entries you've added notes to",
+		Args:        "[export]",
+		Permission:  permissionAuthorised,
+		Handler:     notesCommand,
+	})
+	registry.register(command{
+		Name:        "pins",
+		Description: "Sync pinned messages with your starred entries",
+		Permission:  permissionAuthorised,
+		Handler:     pinsCommand,
+	})
+	registry.register(command{
+		Name:        "muted",
+		Description: "List muted feeds and unmute them",
+		Permission:  permissionAuthorised,
+		Handler:     mutedCommand,
+	})
+	for _, shortcut := range replyShortcuts {
+		registry.register(command{
+			Name:        shortcut.Word,
+			Description: shortcut.Description,
+			Permission:  permissionAuthorised,
+			Handler:     shortcutCommand(shortcut),
+		})
+	}
+	return registry
+}
+
+// register adds a command to the registry, replacing any command with the same name
+func (r *commandRegistry) register(cmd command) {
+	if _, exists := r.commands[cmd.Name]; !exists {
+		r.order = append(r.order, cmd.Name)
+	}
+	r.commands[cmd.Name] = cmd
+}
+
+// helpText generates the /help message from the registered commands
+func (r *commandRegistry) helpText() string {
+	var help strings.Builder
+	help.WriteString("Available commands:\n")
+	for _, name := range r.order {
+		cmd := r.commands[name]
+		fmt.Fprintf(&help, "%s - %s\n", cmd.usage(), cmd.Description)
+	}
+	return help.String()
+}
+
+// setMyCommands registers our commands with Telegram so clients can show them in the command menu
+func (r *commandRegistry) setMyCommands(bot *tgbotapi.BotAPI) error {
+	type botCommand struct {
+		Command     string `json:"command"`
+		Description string `json:"description"`
+	}
+	commands := make([]botCommand, 0, len(r.order))
+	for _, name := range r.order {
+		cmd := r.commands[name]
+		commands = append(commands, botCommand{Command: cmd.Name, Description: cmd.Description})
+	}
+
+	data, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+	_, err = bot.MakeRequest("setMyCommands", url.Values{"commands": {string(data)}})
+	return err
+}
+
+// dispatch runs the handler for the command contained in ctx.message
+func (r *commandRegistry) dispatch(ctx commandContext, allowedUsername string) {
+	name := ctx.message.Command()
+	cmd, ok := r.commands[name]
+
+	// Unknown commands need the same access as authorised ones, so strangers can't make us reply
+	required := permissionAuthorised
+	if ok {
+		required = cmd.Permission
+	}
+	if required != permissionAnyone && !isAuthorisedUser(ctx.message.From, ctx.chatID, allowedUsername) {
+		slog.Error("Received command from invalid user", slog.Int64("chat_id", ctx.message.Chat.ID), slog.String("command", name))
+		return
+	}
+
+	if !ok {
+		if err := ctx.reply(r.unknownCommandText(name)); err != nil {
+			slog.Error("Failed sending message for unknown command", "error", err)
+		}
+		return
+	}
+
+	ctx.args = ctx.message.CommandArguments()
+	if err := cmd.Handler(ctx); err != nil {
+		slog.Error("Failed handling command", "command", name, "error", err)
+		if err := ctx.reply(fmt.Sprintf("Something went wrong running /%s", name)); err != nil {
+			slog.Error("Failed sending message for failed command", "error", err)
+		}
+	}
+}
+
+// unknownCommandText builds a reply for unknown commands, suggesting similar commands where possible
+func (r *commandRegistry) unknownCommandText(name string) string {
+	var suggestions []string
+	for _, known := range r.order {
+		if strings.HasPrefix(known, name) || strings.HasPrefix(name, known) || editDistance(name, known) <= 2 {
+			suggestions = append(suggestions, "/"+known)
+		}
+	}
+	sort.Strings(suggestions)
+
+	text := fmt.Sprintf("Unknown command /%s.", name)
+	if len(suggestions) > 0 {
+		text += fmt.Sprintf(" Did you mean %s?", strings.Join(suggestions, " or "))
+	}
+	return text + " Send /help to see all commands."
+}
+
+// editDistance calculates the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func startCommand(ctx commandContext) error {
+	return ctx.reply(fmt.Sprintf("Your Miniflux Bot is online! Running %v built %v (Commit %s)", version, date, commit[:8]))
+}
+
+func randomUnreadCommand(ctx commandContext) error {
+	unreadEntries, err := ctx.rss.Entries(&miniflux.Filter{Status: miniflux.EntryStatusUnread})
+	if err != nil {
+		return err
+	}
+	if len(unreadEntries.Entries) == 0 {
+		return ctx.reply("You have no unread entries")
+	}
+
+	// Select a random entry from the list
+	entry := unreadEntries.Entries[rand.Intn(len(unreadEntries.Entries))]
+	return sendMsg(ctx.bot, ctx.chatID, ctx.secret, entry, false, false, ctx.store)
+}
+
+func exportCommand(ctx commandContext) error {
+	return exportOPML(ctx.bot, ctx.chatID, ctx.rss)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnknownCommandText(t *testing.T) {
+	var tests = []struct {
+		explanation string
+		command     string
+		suggestion  string
+	}{
+		{
+			"Typo suggests the closest command",
+			"exprot",
+			"/export",
+		}, {
+			"Prefix suggests the full command",
+			"random",
+			"/randomunread",
+		}, {
+			"Unrelated command has no suggestion",
+			"weather",
+			"",
+		},
+	}
+
+	registry := newCommandRegistry()
+	for _, tt := range tests {
+		text := registry.unknownCommandText(tt.command)
+		if tt.suggestion == "" && strings.Contains(text, "Did you mean") {
+			t.Errorf("%s: input [%s], got %q, want no suggestion", tt.explanation, tt.command, text)
+		}
+		if tt.suggestion != "" && !strings.Contains(text, tt.suggestion) {
+			t.Errorf("%s: input [%s], got %q, want suggestion %s", tt.explanation, tt.command, text, tt.suggestion)
+		}
+	}
+}
+
+func TestHelpTextListsCommands(t *testing.T) {
+	registry := newCommandRegistry()
+	registry.register(command{Name: "test", Description: "A test command", Args: "<id>"})
+
+	help := registry.helpText()
+	for _, name := range registry.order {
+		if !strings.Contains(help, "/"+name) {
+			t.Errorf("help text missing /%s: %q", name, help)
+		}
+	}
+	if !strings.Contains(help, "/test <id> - A test command") {
+		t.Errorf("help text missing command usage: %q", help)
+	}
+}
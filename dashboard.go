@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+const (
+	// Setting key the dashboard message's ID is stored under
+	dashboardMessageSetting = "dashboard_message_id"
+	// Setting key the time we last checked for new entries is stored under
+	lastPollSetting = "last_poll_time"
+	// How many entries a category button sends at once
+	browsePageSize = 5
+)
+
+// dashboardEnabled checks whether the dashboard message should be kept up to date
+func dashboardEnabled() bool {
+	return viper.GetBool("TELEGRAM_DASHBOARD")
+}
+
+// categoryUnread is the number of unread entries in a category
+type categoryUnread struct {
+	ID     int64
+	Title  string
+	Unread int
+}
+
+// dashboardStats is everything shown on the dashboard
+type dashboardStats struct {
+	TotalUnread  int
+	Categories   []categoryUnread // Categories with unread entries, most unread first
+	OldestUnread time.Time        // Zero if nothing is unread
+	LastPoll     time.Time        // Zero if we haven't checked for entries yet
+	FeedErrors   []string         // Titles of feeds Miniflux is failing to fetch
+}
+
+// loadDashboardStats gathers the dashboard's numbers from Miniflux
+func loadDashboardStats(rss *miniflux.Client, s store.Store) (dashboardStats, error) {
+	var stats dashboardStats
+
+	feeds, err := rss.Feeds()
+	if err != nil {
+		return stats, err
+	}
+	counters, err := rss.FetchCounters()
+	if err != nil {
+		return stats, err
+	}
+
+	categories := make(map[int64]*categoryUnread)
+	for _, feed := range feeds {
+		if feed.ParsingErrorCount > 0 {
+			stats.FeedErrors = append(stats.FeedErrors, feed.Title)
+		}
+		unread := counters.UnreadCounters[feed.ID]
+		if unread == 0 || feed.Category == nil {
+			continue
+		}
+		stats.TotalUnread += unread
+		category, ok := categories[feed.Category.ID]
+		if !ok {
+			category = &categoryUnread{ID: feed.Category.ID, Title: feed.Category.Title}
+			categories[feed.Category.ID] = category
+		}
+		category.Unread += unread
+	}
+	for _, category := range categories {
+		stats.Categories = append(stats.Categories, *category)
+	}
+	sort.Slice(stats.Categories, func(i, j int) bool {
+		if stats.Categories[i].Unread != stats.Categories[j].Unread {
+			return stats.Categories[i].Unread > stats.Categories[j].Unread
+		}
+		return stats.Categories[i].Title < stats.Categories[j].Title
+	})
+	sort.Strings(stats.FeedErrors)
+
+	oldest, err := rss.Entries(&miniflux.Filter{Status: miniflux.EntryStatusUnread, Order: "published_at", Direction: "asc", Limit: 1})
+	if err != nil {
+		return stats, err
+	}
+	if len(oldest.Entries) > 0 {
+		stats.OldestUnread = oldest.Entries[0].Date
+	}
+
+	if lastPoll, err := s.GetSetting(lastPollSetting); err == nil {
+		stats.LastPoll, _ = time.Parse(time.RFC3339, lastPoll)
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// formatAge describes a duration roughly, like "3d 4h" or "25m"
+func formatAge(age time.Duration) string {
+	age = age.Round(time.Minute)
+	days := int(age.Hours()) / 24
+	hours := int(age.Hours()) % 24
+	minutes := int(age.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// formatDashboard renders the dashboard message text
+func formatDashboard(stats dashboardStats, now time.Time) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "📊 %d unread\n", stats.TotalUnread)
+	for _, category := range stats.Categories {
+		fmt.Fprintf(&text, "  %s: %d\n", category.Title, category.Unread)
+	}
+	if !stats.OldestUnread.IsZero() {
+		fmt.Fprintf(&text, "\nOldest unread: %s ago\n", formatAge(now.Sub(stats.OldestUnread)))
+	}
+	if !stats.LastPoll.IsZero() {
+		fmt.Fprintf(&text, "Last checked: %s ago\n", formatAge(now.Sub(stats.LastPoll)))
+	}
+	if len(stats.FeedErrors) > 0 {
+		fmt.Fprintf(&text, "\n⚠️ Feeds with errors (%d):\n", len(stats.FeedErrors))
+		for _, feed := range stats.FeedErrors {
+			fmt.Fprintf(&text, "  %s\n", feed)
+		}
+	}
+	return truncateMessage(text.String())
+}
+
+// dashboardKeyboard has a button to browse each category with unread entries
+func dashboardKeyboard(secret types.TelegramSecret, stats dashboardStats) tgbotapi.InlineKeyboardMarkup {
+	markup := tgbotapi.NewInlineKeyboardMarkup()
+	row := make([]tgbotapi.InlineKeyboardButton, 0, 2)
+	for _, category := range stats.Categories {
+		label := fmt.Sprintf("%s (%d)", category.Title, category.Unread)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, callbackData(secret, browseCategory, category.ID, "")))
+		if len(row) == 2 {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+			row = make([]tgbotapi.InlineKeyboardButton, 0, 2)
+		}
+	}
+	if len(row) > 0 {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Refresh", callbackData(secret, refreshDashboard, 0, "")),
+	))
+	return markup
+}
+
+// updateDashboard edits the dashboard message, sending and pinning a new one if there isn't one yet
+func updateDashboard(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, s store.Store) error {
+	stats, err := loadDashboardStats(rss, s)
+	if err != nil {
+		return err
+	}
+	text := formatDashboard(stats, time.Now())
+	keyboard := dashboardKeyboard(secret, stats)
+
+	messageID := 0
+	if setting, err := s.GetSetting(dashboardMessageSetting); err == nil {
+		messageID, _ = strconv.Atoi(setting)
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+		edit.ReplyMarkup = &keyboard
+		_, err := bot.Send(edit)
+		if err == nil || isNotModified(err) {
+			return nil
+		}
+		if !isMessageGone(err) {
+			return err
+		}
+		// The dashboard has been deleted, so send a new one
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.DisableNotification = true
+	msg.ReplyMarkup = keyboard
+	message, err := bot.Send(msg)
+	if err != nil {
+		return err
+	}
+	if err := s.SetSetting(dashboardMessageSetting, strconv.Itoa(message.MessageID)); err != nil {
+		return err
+	}
+	_, err = bot.PinChatMessage(tgbotapi.PinChatMessageConfig{
+		ChatID:              chatID,
+		MessageID:           message.MessageID,
+		DisableNotification: true,
+	})
+	return err
+}
+
+// sendCategoryEntries sends the oldest unread entries in a category which aren't already in the chat
+func sendCategoryEntries(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, s store.Store, categoryID int64) (int, error) {
+	tracked, err := s.GetEntries()
+	if err != nil {
+		return 0, err
+	}
+	inChat := make(map[int64]bool, len(tracked))
+	for _, message := range tracked {
+		inChat[message.ID] = true
+	}
+
+	entries, err := rss.CategoryEntries(categoryID, &miniflux.Filter{
+		Status:    miniflux.EntryStatusUnread,
+		Order:     "published_at",
+		Direction: "asc",
+		Limit:     browsePageSize + len(tracked),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, entry := range entries.Entries {
+		if sent >= browsePageSize {
+			break
+		}
+		if inChat[entry.ID] {
+			continue
+		}
+		if err := sendMsg(bot, chatID, secret, entry, true, true, s); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatAge(t *testing.T) {
+	var tests = []struct {
+		age      time.Duration
+		expected string
+	}{
+		{25 * time.Minute, "25m"},
+		{5*time.Hour + 10*time.Minute, "5h 10m"},
+		{76 * time.Hour, "3d 4h"},
+		{20 * time.Second, "0m"},
+	}
+
+	for _, tt := range tests {
+		if age := formatAge(tt.age); age != tt.expected {
+			t.Errorf("age [%v], got %s, want %s", tt.age, age, tt.expected)
+		}
+	}
+}
+
+func TestFormatDashboard(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		stats       dashboardStats
+		expected    string
+		explanation string
+	}{
+		{
+			dashboardStats{},
+			"📊 0 unread\n",
+			"Nothing to report",
+		},
+		{
+			dashboardStats{
+				TotalUnread:  12,
+				Categories:   []categoryUnread{{ID: 1, Title: "Tech", Unread: 10}, {ID: 2, Title: "News", Unread: 2}},
+				OldestUnread: now.Add(-50 * time.Hour),
+				LastPoll:     now.Add(-5 * time.Minute),
+				FeedErrors:   []string{"Broken Blog"},
+			},
+			"📊 12 unread\n  Tech: 10\n  News: 2\n\nOldest unread: 2d 2h ago\nLast checked: 5m ago\n\n⚠️ Feeds with errors (1):\n  Broken Blog\n",
+			"Everything shown",
+		},
+	}
+
+	for _, tt := range tests {
+		if text := formatDashboard(tt.stats, now); text != tt.expected {
+			t.Errorf("%s: got %q, want %q", tt.explanation, text, tt.expected)
+		}
+	}
+}
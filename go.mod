@@ -4,8 +4,10 @@ go 1.21
 
 require (
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
+	github.com/lib/pq v1.10.9
 	github.com/pressly/goose/v3 v3.16.0
 	github.com/spf13/viper v1.17.0
+	golang.org/x/net v0.18.0
 	golang.org/x/sys v0.14.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	miniflux.app v1.0.46
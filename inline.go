@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	miniflux "miniflux.app/client"
+)
+
+const (
+	// Telegram accepts at most 50 results per inline query answer
+	maxInlineResults = 50
+	// How far back read entries are still included in inline results
+	inlineRecentWindow = 7 * 24 * time.Hour
+)
+
+// searchInlineEntries looks up entries matching query across unread, starred
+// and recently published entries, newest first and without duplicates
+func searchInlineEntries(rss *miniflux.Client, query string) (miniflux.Entries, error) {
+	filters := []*miniflux.Filter{
+		{Search: query, Status: miniflux.EntryStatusUnread},
+		{Search: query, Starred: miniflux.FilterOnlyStarred},
+		{Search: query, After: time.Now().Add(-inlineRecentWindow).Unix()},
+	}
+
+	seen := make(map[int64]bool)
+	results := make(miniflux.Entries, 0, maxInlineResults)
+	for _, filter := range filters {
+		filter.Order = "published_at"
+		filter.Direction = "desc"
+		filter.Limit = maxInlineResults
+
+		entries, err := rss.Entries(filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries.Entries {
+			if seen[entry.ID] || len(results) == maxInlineResults {
+				continue
+			}
+			seen[entry.ID] = true
+			results = append(results, entry)
+		}
+	}
+	return results, nil
+}
+
+// answerInlineQuery replies to an inline query with matching Miniflux entries
+func answerInlineQuery(bot *tgbotapi.BotAPI, rss *miniflux.Client, query *tgbotapi.InlineQuery) error {
+	entries, err := searchInlineEntries(rss, query.Query)
+	if err != nil {
+		return err
+	}
+
+	results := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		results = append(results, tgbotapi.InlineQueryResultArticle{
+			Type:  "article",
+			ID:    strconv.FormatInt(entry.ID, 10),
+			Title: entry.Title,
+			InputMessageContent: tgbotapi.InputTextMessageContent{
+				Text:      formatEntry(entry),
+				ParseMode: "MarkdownV2",
+			},
+			URL:         entry.URL,
+			Description: entry.Feed.Title,
+		})
+	}
+
+	_, err = bot.AnswerInlineQuery(tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     60,
+		IsPersonal:    true,
+	})
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/telegraph"
+	miniflux "miniflux.app/client"
+)
+
+// Setting key the Telegraph account token is stored under
+const telegraphTokenSetting = "telegraph_access_token"
+
+// instantViewURL returns the Telegraph page URL for an entry if one has been published
+func instantViewURL(store store.Store, entryID int64) string {
+	if !viper.GetBool("TELEGRAPH_ENABLED") {
+		return ""
+	}
+	page, err := store.GetTelegraphPage(entryID)
+	if err != nil {
+		return ""
+	}
+	return page.URL
+}
+
+// telegraphClient returns a Telegraph client, creating an account the first time it's used
+func telegraphClient(s store.Store) (*telegraph.Client, error) {
+	token, err := s.GetSetting(telegraphTokenSetting)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	client := telegraph.New(viper.GetString("TELEGRAPH_URL"), token)
+	if token != "" {
+		return client, nil
+	}
+
+	account, err := client.CreateAccount("miniflux-bot", viper.GetString("TELEGRAPH_AUTHOR"))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SetSetting(telegraphTokenSetting, account.AccessToken); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// publishInstantView publishes an entry's full content to Telegraph, reusing any page already published for it
+func publishInstantView(rss *miniflux.Client, s store.Store, entryID int64) (models.TelegraphPage, error) {
+	page, err := s.GetTelegraphPage(entryID)
+	if err == nil {
+		return page, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return page, err
+	}
+
+	entry, err := rss.Entry(entryID)
+	if err != nil {
+		return page, err
+	}
+
+	content, err := fetchOriginalContent(entryID)
+	if err != nil || strings.TrimSpace(content) == "" {
+		// Fall back to whatever content the feed included
+		content = entry.Content
+	}
+
+	nodes, err := telegraph.HTMLToNodes(content)
+	if err != nil {
+		return page, err
+	}
+	if len(nodes) == 0 {
+		return page, errors.New("entry has no content to publish")
+	}
+
+	client, err := telegraphClient(s)
+	if err != nil {
+		return page, err
+	}
+
+	published, err := client.CreatePage(entry.Title, entry.Feed.Title, entry.URL, nodes)
+	if err != nil {
+		return page, err
+	}
+
+	page = models.TelegraphPage{
+		EntryID: entryID,
+		Path:    published.Path,
+		URL:     published.URL,
+		Created: time.Now(),
+	}
+	return page, s.InsertTelegraphPage(page)
+}
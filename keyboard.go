@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/parse"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// The keyboard entries get unless KEYBOARD_LAYOUT says otherwise.
+// Rows are separated by semicolons and buttons by commas.
+const defaultKeyboardLayout = "read,star,select;readhere,later,mute;delete,deleteread;open,comments;instantview;notes"
+
+// Labels for each button, some buttons use a different label depending on the entry's state
+var defaultKeyboardLabels = map[string]string{
+	"read":        "Mark as read",
+	"unread":      "Mark as unread",
+	"star":        "Star",
+	"unstar":      "Unstar",
+	"readhere":    "Read here",
+	"later":       "Later",
+	"delete":      "Delete message",
+	"deleteread":  "Delete & mark as read",
+	"instantview": "Instant View",
+	"publish":     "Publish Instant View",
+	"notes":       "📝 Notes",
+	"select":      "☐ Select",
+	"selected":    "☑ Selected",
+	"mute":        "Mute feed",
+	"open":        "Open in Miniflux",
+	"comments":    "Comments",
+}
+
+// entryExtras is bot-side state about an entry that's shown on its keyboard
+type entryExtras struct {
+	InstantView string // URL of the entry's published Telegraph page
+	Notes       int    // Number of notes attached to the entry
+	Selected    bool   // Whether the entry is selected for a bulk action
+}
+
+func loadEntryExtras(store store.Store, entryID int64) entryExtras {
+	extras := entryExtras{
+		InstantView: instantViewURL(store, entryID),
+		Selected:    isSelected(store, entryID),
+	}
+	if notes, err := store.GetNotesByEntryID(entryID); err == nil {
+		extras.Notes = len(notes)
+	}
+	return extras
+}
+
+// buttonContext contains what a button needs to render itself for an entry
+type buttonContext struct {
+	entry  *miniflux.Entry
+	secret types.TelegramSecret
+	extras entryExtras
+	labels map[string]string
+}
+
+func (ctx buttonContext) data(label string, action string) (tgbotapi.InlineKeyboardButton, bool) {
+	return tgbotapi.NewInlineKeyboardButtonData(ctx.labels[label], callbackData(ctx.secret, action, ctx.entry.ID, "")), true
+}
+
+// keyboardButton renders a button for an entry, returning false if the button shouldn't be shown
+type keyboardButton func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool)
+
+// keyboardButtons are all the buttons that can be used in a keyboard layout
+var keyboardButtons = map[string]keyboardButton{
+	"read": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		if ctx.entry.Status == "unread" {
+			return ctx.data("read", markRead)
+		}
+		return ctx.data("unread", markUnread)
+	},
+	"star": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		if ctx.entry.Starred {
+			return ctx.data("unstar", star)
+		}
+		return ctx.data("star", star)
+	},
+	"select": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		if ctx.extras.Selected {
+			return ctx.data("selected", selectEntry)
+		}
+		return ctx.data("select", selectEntry)
+	},
+	"readhere": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		return ctx.data("readhere", readHere)
+	},
+	"later": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		return ctx.data("later", later)
+	},
+	"mute": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		return ctx.data("mute", mute)
+	},
+	"delete": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		return tgbotapi.NewInlineKeyboardButtonData(ctx.labels["delete"], callbackData(ctx.secret, deleteMessage, 0, "")), true
+	},
+	"deleteread": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		return ctx.data("deleteread", deleteAndMark)
+	},
+	"instantview": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		// Link to the Instant View if we've published one, otherwise offer to publish it
+		if ctx.extras.InstantView != "" {
+			return tgbotapi.NewInlineKeyboardButtonURL(ctx.labels["instantview"], ctx.extras.InstantView), true
+		}
+		if viper.GetBool("TELEGRAPH_ENABLED") {
+			return ctx.data("publish", telegraphPublish)
+		}
+		return tgbotapi.InlineKeyboardButton{}, false
+	},
+	"notes": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		// Only shown once the entry has notes attached
+		if ctx.extras.Notes == 0 {
+			return tgbotapi.InlineKeyboardButton{}, false
+		}
+		label := fmt.Sprintf("%s (%d)", ctx.labels["notes"], ctx.extras.Notes)
+		return tgbotapi.NewInlineKeyboardButtonData(label, callbackData(ctx.secret, showNotes, ctx.entry.ID, "")), true
+	},
+	"open": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		// The bot might talk to Miniflux over an internal address, so allow a different URL for links
+		baseURL := viper.GetString("MINIFLUX_PUBLIC_URL")
+		if baseURL == "" {
+			baseURL = viper.GetString("MINIFLUX_URL")
+		}
+		return tgbotapi.NewInlineKeyboardButtonURL(ctx.labels["open"], minifluxEntryURL(baseURL, ctx.entry)), true
+	},
+	"comments": func(ctx buttonContext) (tgbotapi.InlineKeyboardButton, bool) {
+		// Only some feeds (HN, Lobsters, Reddit etc.) have a discussion thread
+		if ctx.entry.CommentsURL == "" {
+			return tgbotapi.InlineKeyboardButton{}, false
+		}
+		return tgbotapi.NewInlineKeyboardButtonURL(ctx.labels["comments"], ctx.entry.CommentsURL), true
+	},
+}
+
+// minifluxEntryURL links to an entry in the Miniflux web UI.
+// Entries are opened through their feed so the link works whatever their status is.
+func minifluxEntryURL(baseURL string, entry *miniflux.Entry) string {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if entry.FeedID == 0 {
+		return fmt.Sprintf("%s/unread/entry/%d", baseURL, entry.ID)
+	}
+	return fmt.Sprintf("%s/feed/%d/entry/%d", baseURL, entry.FeedID, entry.ID)
+}
+
+// keyboardButtonNames lists the buttons that can be used in a layout
+func keyboardButtonNames() []string {
+	names := make([]string, 0, len(keyboardButtons))
+	for name := range keyboardButtons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// keyboardLayout returns the layout for a category, falling back to the global layout
+func keyboardLayout(categoryID int64) [][]string {
+	layout := viper.GetString("KEYBOARD_LAYOUT")
+	if categoryLayout := viper.GetString(fmt.Sprintf("KEYBOARD_LAYOUT_%d", categoryID)); categoryLayout != "" {
+		layout = categoryLayout
+	}
+
+	rows, err := parse.KeyboardLayout(layout, keyboardButtonNames())
+	if err != nil {
+		slog.Error("Invalid keyboard layout, using default", "error", err, "category", categoryID)
+		rows, _ = parse.KeyboardLayout(defaultKeyboardLayout, keyboardButtonNames())
+	}
+	return rows
+}
+
+// keyboardLabels returns the button labels for a category, with category
+// labels overriding global labels which override the defaults
+func keyboardLabels(categoryID int64) map[string]string {
+	labels := make(map[string]string, len(defaultKeyboardLabels))
+	for button, label := range defaultKeyboardLabels {
+		labels[button] = label
+	}
+
+	for _, key := range []string{"KEYBOARD_LABELS", fmt.Sprintf("KEYBOARD_LABELS_%d", categoryID)} {
+		overrides, err := parse.KeyboardLabels(viper.GetString(key))
+		if err != nil {
+			slog.Error("Invalid keyboard labels, ignoring", "error", err, "setting", key)
+			continue
+		}
+		for button, label := range overrides {
+			labels[button] = label
+		}
+	}
+	return labels
+}
+
+// validateKeyboardConfig checks the global keyboard settings so mistakes are caught on startup
+func validateKeyboardConfig() error {
+	if _, err := parse.KeyboardLayout(viper.GetString("KEYBOARD_LAYOUT"), keyboardButtonNames()); err != nil {
+		return err
+	}
+	_, err := parse.KeyboardLabels(viper.GetString("KEYBOARD_LABELS"))
+	return err
+}
+
+func generateKeyboard(entry *miniflux.Entry, secret types.TelegramSecret, extras entryExtras) tgbotapi.InlineKeyboardMarkup {
+	categoryID := entryCategoryID(entry)
+
+	ctx := buttonContext{
+		entry:  entry,
+		secret: secret,
+		extras: extras,
+		labels: keyboardLabels(categoryID),
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup()
+	for _, row := range keyboardLayout(categoryID) {
+		buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+		for _, name := range row {
+			if button, ok := keyboardButtons[name](ctx); ok {
+				buttons = append(buttons, button)
+			}
+		}
+		// Rows can end up empty if none of their buttons apply to this entry
+		if len(buttons) > 0 {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, buttons)
+		}
+	}
+	return markup
+}
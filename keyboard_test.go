@@ -0,0 +1,111 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/callback"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+func TestMinifluxEntryURL(t *testing.T) {
+	var tests = []struct {
+		baseURL  string
+		entry    miniflux.Entry
+		expected string
+	}{
+		{"https://reader.miniflux.app", miniflux.Entry{ID: 42, FeedID: 7}, "https://reader.miniflux.app/feed/7/entry/42"},
+		{"https://rss.example.com/", miniflux.Entry{ID: 42, FeedID: 7}, "https://rss.example.com/feed/7/entry/42"},
+		{"https://rss.example.com", miniflux.Entry{ID: 42}, "https://rss.example.com/unread/entry/42"},
+	}
+
+	for _, tt := range tests {
+		if url := minifluxEntryURL(tt.baseURL, &tt.entry); url != tt.expected {
+			t.Errorf("base [%s], got %s, want %s", tt.baseURL, url, tt.expected)
+		}
+	}
+}
+
+func TestGenerateKeyboardURLButtons(t *testing.T) {
+	viper.Set("KEYBOARD_LAYOUT", "open,comments")
+	defer viper.Set("KEYBOARD_LAYOUT", defaultKeyboardLayout)
+
+	var tests = []struct {
+		entry       miniflux.Entry
+		buttons     int
+		explanation string
+	}{
+		{miniflux.Entry{ID: 1, CommentsURL: "https://news.ycombinator.com/item?id=1"}, 2, "Entries with a discussion get a comments button"},
+		{miniflux.Entry{ID: 1}, 1, "Comments button is skipped without a discussion"},
+	}
+
+	for _, tt := range tests {
+		keyboard := generateKeyboard(&tt.entry, "testsecret", entryExtras{})
+		if len(keyboard.InlineKeyboard) != 1 || len(keyboard.InlineKeyboard[0]) != tt.buttons {
+			t.Errorf("%s: got %v", tt.explanation, keyboard.InlineKeyboard)
+		}
+	}
+}
+
+func TestDecodeCallback(t *testing.T) {
+	secret := types.TelegramSecret("oohue2Oob3leyah")
+	var tests = []struct {
+		raw         string
+		expected    callback.Data
+		valid       bool
+		explanation string
+	}{
+		{callbackData(secret, markRead, 42, ""), callback.Data{Action: markRead, EntryID: 42}, true, "Signed data"},
+		{"oohue2Oob3leyah:markRead:42", callback.Data{Action: markRead, EntryID: 42}, true, "Legacy data maps to the short action code"},
+		{"oohue2Oob3leyah:deleteAndMark:42", callback.Data{Action: deleteAndMark, EntryID: 42}, true, "Every released legacy action is mapped"},
+		{"oohue2Oob3leyah:readPage:42:3", callback.Data{}, false, "Legacy data with an action added after the format changed"},
+		{"oohue2Oob3leyah:selectEntry:42", callback.Data{}, false, "Legacy data with an action older versions didn't have"},
+		{"anothersecret:markRead:42", callback.Data{}, false, "Legacy data with the wrong secret"},
+	}
+
+	for _, tt := range tests {
+		data, err := decodeCallback(secret, tt.raw)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: got error %v, want valid %v", tt.explanation, err, tt.valid)
+		}
+		if tt.valid && data != tt.expected {
+			t.Errorf("%s: got %+v, want %+v", tt.explanation, data, tt.expected)
+		}
+	}
+}
+
+func TestReaderKeyboard(t *testing.T) {
+	secret := types.TelegramSecret("oohue2Oob3leyah")
+
+	var tests = []struct {
+		page        int
+		total       int
+		expected    []string
+		explanation string
+	}{
+		{0, 3, []string{"", "1"}, "The first page only links forward"},
+		{1, 3, []string{"0", "", "2"}, "Middle pages link both ways"},
+		{2, 3, []string{"1", ""}, "The last page only links back"},
+		{0, 1, []string{""}, "Single page articles have no page buttons"},
+	}
+
+	for _, tt := range tests {
+		row := readerKeyboard(secret, 42, tt.page, tt.total).InlineKeyboard[0]
+		pages := make([]string, 0, len(row))
+		for _, button := range row {
+			data, err := callback.Decode(secret, *button.CallbackData)
+			if err != nil {
+				t.Fatalf("%s: decoding button data: %v", tt.explanation, err)
+			}
+			if data.Action == readPage && data.EntryID != 42 {
+				t.Errorf("%s: page button for entry %d, want 42", tt.explanation, data.EntryID)
+			}
+			pages = append(pages, data.Param)
+		}
+		if !reflect.DeepEqual(pages, tt.expected) {
+			t.Errorf("%s: got pages %q, want %q", tt.explanation, pages, tt.expected)
+		}
+	}
+}
@@ -2,9 +2,9 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"log/slog"
-	"math/rand"
 	"os"
 	"strconv"
 	"strings"
@@ -12,20 +12,42 @@ import (
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/callback"
 	"go.jloh.dev/miniflux-telegram-bot/models"
 	"go.jloh.dev/miniflux-telegram-bot/parse"
 	"go.jloh.dev/miniflux-telegram-bot/store"
-	"go.jloh.dev/miniflux-telegram-bot/store/sqlite"
+	"go.jloh.dev/miniflux-telegram-bot/telegraph"
 	"go.jloh.dev/miniflux-telegram-bot/types"
 	miniflux "miniflux.app/client"
 )
 
+// Actions are sent as short codes to leave room in the 64 byte callback data
 const (
-	markRead      string = "markRead"
-	markUnread    string = "markUnread"
-	deleteAndMark string = "deleteAndMark"
-	deleteMessage string = "deleteMessage"
-	star          string = "star"
+	markRead         string = "r"
+	markUnread       string = "u"
+	deleteAndMark    string = "dr"
+	deleteMessage    string = "d"
+	star             string = "s"
+	readHere         string = "rh"
+	readPage         string = "p"
+	noop             string = "n"
+	telegraphPublish string = "tp"
+	later            string = "l"
+	snooze           string = "z"
+	cancelLater      string = "lc"
+	showNotes        string = "nt"
+	mute             string = "m"
+	muteFeed         string = "mf"
+	unmuteFeed       string = "um"
+	undoDelete       string = "ud"
+	selectEntry      string = "sl"
+	bulkRead         string = "br"
+	bulkStar         string = "bs"
+	bulkDelete       string = "bd"
+	bulkClear        string = "bc"
+	browseCategory   string = "bw"
+	refreshDashboard string = "rf"
+	tokenAction      string = "t"
 )
 
 var (
@@ -34,19 +56,33 @@ var (
 	date    = "unknown"
 )
 
-//go:embed migrations/*.sql
+//go:embed migrations/*.sql migrations/postgres/*.sql
 var embedMigrations embed.FS
 
 func main() {
 	// Get config
 	viper.SetDefault("MINIFLUX_URL", "https://reader.miniflux.app")
+	viper.SetDefault("MINIFLUX_PUBLIC_URL", "")
 	viper.SetDefault("MINIFLUX_SLEEP_TIME", 30)
 	viper.SetDefault("TELEGRAM_CHAT_ID", 0)
 	viper.SetDefault("TELEGRAM_POLL_TIMEOUT", 120)
 	viper.SetDefault("TELEGRAM_SILENT_NOTIFICATION", true)
 	viper.SetDefault("TELEGRAM_CLEANUP_MESSAGES", true)
+	viper.SetDefault("TELEGRAM_CLEANUP_INTERVAL", 10)
+	viper.SetDefault("TELEGRAM_CLEANUP_GONE", "delete")
+	viper.SetDefault("METRICS_LISTEN_ADDR", "")
 	viper.SetDefault("TELEGRAM_SECRET", "")
 	viper.SetDefault("TELEGRAM_ALLOWED_USERNAME", "")
+	viper.SetDefault("TELEGRAM_UNDO_WINDOW", 30)
+	viper.SetDefault("TELEGRAM_PIN_STARRED", false)
+	viper.SetDefault("TELEGRAM_DASHBOARD", false)
+	viper.SetDefault("KEYBOARD_LAYOUT", defaultKeyboardLayout)
+	viper.SetDefault("KEYBOARD_LABELS", "")
+	viper.SetDefault("TELEGRAPH_ENABLED", false)
+	viper.SetDefault("TELEGRAPH_URL", telegraph.DefaultURL)
+	viper.SetDefault("TELEGRAPH_AUTHOR", "Miniflux Bot")
+	viper.SetDefault("STORE_DRIVER", storeSQLite)
+	viper.SetDefault("STORE_DSN", "")
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("/etc/miniflux_bot/")
@@ -58,9 +94,6 @@ func main() {
 		slog.Warn("Error reading in config file", "error", err)
 	}
 
-	// Pass migrations to storage
-	sqlite.EmbedMigrations = embedMigrations
-
 	// Set ChatID
 	chatID := viper.GetInt64("TELEGRAM_CHAT_ID")
 	if chatID == 0 {
@@ -74,6 +107,27 @@ func main() {
 		slog.Error("TELEGRAM_SECRET setting is invalid", "error", err)
 		os.Exit(1)
 	}
+	if len(telegramSecret) < parse.RecommendedSecretLength {
+		slog.Warn("TELEGRAM_SECRET is short, consider a longer random secret", "recommended_length", parse.RecommendedSecretLength)
+	}
+
+	// Check the keyboard layout and labels are valid
+	if err := validateKeyboardConfig(); err != nil {
+		slog.Error("Keyboard settings are invalid", "error", err)
+		os.Exit(1)
+	}
+
+	// Check the cleanup policy is valid
+	if err := validateCleanupConfig(); err != nil {
+		slog.Error("Cleanup settings are invalid", "error", err)
+		os.Exit(1)
+	}
+
+	// Check the storage settings are valid
+	if err := validateStoreConfig(); err != nil {
+		slog.Error("Storage settings are invalid", "error", err)
+		os.Exit(1)
+	}
 
 	// Setup RSS instance
 	rss := miniflux.New(viper.GetString("MINIFLUX_URL"), viper.GetString("MINIFLUX_API_KEY"))
@@ -89,7 +143,7 @@ func main() {
 	latestEntryID := latestEntries.Entries[0].ID
 
 	// Get our DB going
-	store := sqlite.New()
+	store := openStore()
 
 	// Initialise Telegram bot instance
 	bot, err := tgbotapi.NewBotAPI(viper.GetString("TELEGRAM_BOT_TOKEN"))
@@ -100,8 +154,39 @@ func main() {
 
 	slog.Info("Starting Miniflux Bot", "version", version, "built", date, "commit", commit[:8])
 
+	// Register our commands with Telegram so they show up in the command menu
+	commands := newCommandRegistry()
+	if err := commands.setMyCommands(bot); err != nil {
+		slog.Warn("Failed registering commands with Telegram", "error", err)
+	}
+
+	// Catch up on anything that changed while we were offline before handling any updates,
+	// so callbacks pressed in the meantime act on messages that match Miniflux. Keyboards and
+	// text are always brought up to date, read messages are only cleaned up if that's turned on.
+	slog.Info("Syncing messages with Miniflux")
+	summary := syncMessages(bot, chatID, telegramSecret, rss, store, time.Now(), viper.GetBool("TELEGRAM_CLEANUP_MESSAGES"))
+	slog.Info("Startup sync finished", summary.logAttrs()...)
+	if pinStarredEnabled() {
+		if pinned, unpinned, err := syncAllPins(bot, chatID, telegramSecret, rss, store, false); err != nil {
+			slog.Error("Failed syncing pins", "error", err)
+		} else {
+			slog.Info("Startup pin sync finished", "pinned", pinned, "unpinned", unpinned)
+		}
+	}
+
 	// Start listening for messages from Telegram
-	go listenForMessages(bot, chatID, telegramSecret, rss, store)
+	go listenForMessages(bot, chatID, telegramSecret, rss, store, commands)
+
+	// Send entries again once their snooze finishes
+	go sendSnoozedEntries(bot, chatID, telegramSecret, rss, store)
+
+	// Remove deleted messages for good once they can't be undone
+	go removeExpiredDeletes(bot, chatID, store)
+
+	// Expose sync metrics if asked to
+	if addr := viper.GetString("METRICS_LISTEN_ADDR"); addr != "" {
+		go serveMetrics(addr)
+	}
 
 	// Cleanup & update messages
 	if viper.GetBool("TELEGRAM_CLEANUP_MESSAGES") {
@@ -116,12 +201,19 @@ func main() {
 		if err != nil {
 			slog.Error("Failed getting entries", "error", err)
 		} else {
+			if err := store.SetSetting(lastPollSetting, time.Now().UTC().Format(time.RFC3339)); err != nil {
+				slog.Error("Failed saving last poll time", "error", err)
+			}
 			if entries.Total != 0 {
+				muted := mutedFeeds(store, time.Now())
 				for _, entry := range entries.Entries {
 					latestEntryID = entry.ID
 					if ignoredCategoryID(entry.Feed.Category.ID) {
 						slog.Info("Skipping entry as it's in an ignored category", "entry", entry.ID)
 						continue
+					} else if muted[entry.FeedID] {
+						slog.Info("Skipping entry as its feed is muted", "entry", entry.ID, "feed", entry.FeedID)
+						continue
 					} else {
 						if err := sendMsg(bot, chatID, telegramSecret, entry, viper.GetBool("TELEGRAM_SILENT_NOTIFICATION"), true, store); err != nil {
 							slog.Error("Failed sending message", "error", err, "entry", entry.ID)
@@ -132,11 +224,32 @@ func main() {
 				}
 			}
 		}
+
+		// Tidy up mutes which have ended
+		if _, err := store.DeleteExpiredFeedMutes(time.Now()); err != nil {
+			slog.Error("Failed deleting expired feed mutes", "error", err)
+		}
+
+		// Garbage collect callback tokens that can no longer be used, whether or not cleanup is on
+		if removed, err := store.DeleteExpiredCallbackTokens(time.Now()); err != nil {
+			slog.Error("Failed deleting expired callback tokens", "error", err)
+		} else if removed > 0 {
+			slog.Info("Cleaned up expired callback tokens", "removed", removed)
+		}
+
+		if dashboardEnabled() {
+			if err := updateDashboard(bot, chatID, telegramSecret, rss, store); err != nil {
+				slog.Error("Failed updating dashboard", "error", err)
+			}
+		}
 		time.Sleep(time.Duration(viper.GetInt64("MINIFLUX_SLEEP_TIME")) * time.Minute)
 	}
 }
 
-func listenForMessages(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, store store.Store) {
+func listenForMessages(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, store store.Store, commands *commandRegistry) {
+	// Start from the oldest update Telegram still has, so buttons pressed and commands sent
+	// while we were offline are still handled. The startup sync has already run by now, so
+	// they act on messages that match Miniflux.
 	poll := tgbotapi.NewUpdate(0)
 	poll.Timeout = viper.GetInt("TELEGRAM_POLL_TIMEOUT")
 
@@ -148,157 +261,138 @@ func listenForMessages(bot *tgbotapi.BotAPI, chatID int64, secret types.Telegram
 	allowed_username := viper.GetString("TELEGRAM_ALLOWED_USERNAME")
 
 	for update := range updates {
+		// Check whether we've got an inline query to search entries with
+		if update.InlineQuery != nil {
+			if !isAuthorisedUser(update.InlineQuery.From, chatID, allowed_username) {
+				slog.Warn("Inline query from unauthorised user, ignoring", "user_id", update.InlineQuery.From.ID)
+				continue
+			}
+			if err := answerInlineQuery(bot, rss, update.InlineQuery); err != nil {
+				slog.Error("Failed answering inline query", "error", err)
+			}
+			continue
+		}
+		// Check whether we've been sent an OPML file to import
+		if update.Message != nil && isOPMLDocument(update.Message.Document) {
+			if !isAuthorisedUser(update.Message.From, chatID, allowed_username) {
+				slog.Warn("Document from unauthorised user, ignoring", "chat_id", update.Message.Chat.ID)
+				continue
+			}
+			summary, err := importOPML(bot, rss, update.Message.Document)
+			if err != nil {
+				slog.Error("Failed importing OPML file", "error", err)
+				summary = "Failed importing OPML file into Miniflux"
+			}
+			if err = sendText(bot, update.Message.Chat.ID, summary, false); err != nil {
+				slog.Error("Failed sending message for OPML import", "error", err)
+			}
+			continue
+		}
 		// Check whether we're a command
 		if update.Message != nil && update.Message.IsCommand() {
-			if allowed_username != "" && update.Message.From.UserName != allowed_username {
-				slog.Error("Received command from invalid user", slog.String("user", update.Message.From.UserName))
+			commands.dispatch(commandContext{
+				bot:     bot,
+				chatID:  chatID,
+				secret:  secret,
+				rss:     rss,
+				store:   store,
+				message: update.Message,
+			}, allowed_username)
+		}
+		// Check whether we've got a reply to one of our entry messages, either a shortcut or a note
+		if update.Message != nil && !update.Message.IsCommand() && update.Message.Text != "" && isReplyToBot(bot, update.Message) {
+			if !isAuthorisedUser(update.Message.From, chatID, allowed_username) {
+				slog.Warn("Reply from unauthorised user, ignoring", "chat_id", update.Message.Chat.ID)
 				continue
 			}
-			switch update.Message.Command() {
-			case "randomunread":
-				unreadEntries, err := rss.Entries(&miniflux.Filter{Status: miniflux.EntryStatusUnread})
-				if err != nil {
-					slog.Error("Failed getting unread entries from Miniflux", "error", err)
-				}
-
-				// Select a random entry from the list
-
-				if err := sendMsg(bot, chatID, secret, unreadEntries.Entries[rand.Intn(len(unreadEntries.Entries))], false, false, store); err != nil {
-					slog.Error("Failed sending message for random entry", "error", err)
-				}
-			case "start":
-				startMessage := fmt.Sprintf("Your Miniflux Bot is online! Running %v built %v (Commit %s)", version, date, commit[:8])
-				if err = sendText(bot, chatID, startMessage, false); err != nil {
-					slog.Error("Failed sending message for start command", "error", err)
+			// Message IDs are numbered per chat, so replies elsewhere would match unrelated entries
+			if !isInEntryChat(update.Message, chatID) {
+				slog.Warn("Reply from unexpected chat ID, ignoring", "chat_id", update.Message.Chat.ID)
+				continue
+			}
+			if action, ok := shortcutAction(update.Message.Text); ok {
+				if err := runShortcut(bot, chatID, secret, rss, store, update.Message, action); err != nil {
+					slog.Error("Failed running reply shortcut", "error", err, "action", action)
 				}
+			} else if err := saveNote(bot, chatID, secret, rss, store, update.Message); err != nil {
+				slog.Error("Failed saving note", "error", err)
 			}
 		}
 		// Check whether we've got a Callback Query
 		if update.CallbackQuery != nil {
 
-			// Double check if the callback is from our expected chat
-			if update.CallbackQuery.From.ID != int(chatID) {
-				slog.Warn("Callback from unexpected chat ID, ignoring", "chat_id", update.CallbackQuery.From.ID)
+			// Double check if the callback is from someone allowed to use the bot
+			if !isAuthorisedUser(update.CallbackQuery.From, chatID, allowed_username) {
+				slog.Warn("Callback from unauthorised user, ignoring", "user_id", update.CallbackQuery.From.ID)
 				continue
 			}
 
-			var entryID int64
-			// Split our string
-			callback := strings.Split(update.CallbackQuery.Data, ":")
-
-			// Check our secret
-			if callback[0] != string(secret) {
-				slog.Warn("Callback contained invalid secret, ignoring", "callback", callback)
+			// Check the callback was signed by us
+			data, err := decodeCallback(secret, update.CallbackQuery.Data)
+			if err != nil {
+				slog.Warn("Callback contained invalid data, ignoring", "error", err)
 				continue
 			}
 
-			// If we've got an entry, try and get it
-			if len(callback) == 3 {
-				entryID, err = strconv.ParseInt(callback[2], 10, 64)
-				if err != nil {
-					slog.Error("Failed parsing entry ID", "error", err)
-				}
+			action := entryAction{
+				bot:       bot,
+				chatID:    chatID,
+				secret:    secret,
+				rss:       rss,
+				store:     store,
+				messageID: update.CallbackQuery.Message.MessageID,
+				entryID:   data.EntryID,
+				param:     data.Param,
 			}
 
-			switch callback[1] {
-			case markRead:
-				if err := rss.UpdateEntries([]int64{entryID}, "read"); err != nil {
-					answerCallback(bot, update.CallbackQuery.ID, "Error marking entry as read")
-				} else {
-					go answerCallback(bot, update.CallbackQuery.ID, "Marked entry as read")
-					go updateKeyboard(bot, chatID, secret, rss, update.CallbackQuery.Message.MessageID, entryID)
-					go store.UpdateEntryTime(entryID, time.Now())
-				}
-			case markUnread:
-				if err := rss.UpdateEntries([]int64{entryID}, "unread"); err != nil {
-					answerCallback(bot, update.CallbackQuery.ID, "Error marking entry as unread")
-				} else {
-					go answerCallback(bot, update.CallbackQuery.ID, "Marked entry as unread")
-					go updateKeyboard(bot, chatID, secret, rss, update.CallbackQuery.Message.MessageID, entryID)
-					go store.UpdateEntryTime(entryID, time.Now())
+			// Token buttons keep their payload in storage
+			var token *models.CallbackToken
+			if data.Action == tokenAction {
+				payload, err := resolveCallbackToken(store, data.Param, chatID, time.Now())
+				if errors.Is(err, errCallbackTokenWrongChat) {
+					slog.Warn("Callback token for unexpected chat ID, ignoring", "chat_id", payload.ChatID)
+					continue
+				} else if err != nil {
+					slog.Warn("Callback contained unusable token, ignoring", "error", err)
+					answerCallback(bot, update.CallbackQuery.ID, "This button has expired")
+					continue
 				}
-			case deleteAndMark:
-				if err := rss.UpdateEntries([]int64{entryID}, "read"); err != nil {
-					answerCallback(bot, update.CallbackQuery.ID, "Error marking entry as read")
-				} else {
-					bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, update.CallbackQuery.Message.MessageID))
-					store.DeleteEntryByID(entryID)
-					answerCallback(bot, update.CallbackQuery.ID, "Deleted message & marked as read")
+				data.Action = payload.Action
+				action.entryIDs = payload.EntryIDs
+				if len(payload.EntryIDs) > 0 {
+					action.entryID = payload.EntryIDs[0]
 				}
-			case deleteMessage:
-				bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, update.CallbackQuery.Message.MessageID))
-				store.DeleteEntryByTelegramID(update.CallbackQuery.Message.MessageID)
-				answerCallback(bot, update.CallbackQuery.ID, "Deleted message")
-			case star:
-				if err := rss.ToggleBookmark(entryID); err != nil {
-					answerCallback(bot, update.CallbackQuery.ID, "Error updating Miniflux entry")
-					fmt.Printf("Erorr talking to Miniflux: %v\n", err)
-				} else {
-					go answerCallback(bot, update.CallbackQuery.ID, "Updated entry")
-					go updateKeyboard(bot, chatID, secret, rss, update.CallbackQuery.Message.MessageID, entryID)
-					go store.UpdateEntryTime(entryID, time.Now())
+				action.param = payload.Param
+				token = &payload
+			}
+
+			reply, err := action.run(data.Action)
+			if err != nil {
+				slog.Error("Failed handling callback", "action", data.Action, "error", err, "entry", action.entryID)
+			} else if token != nil {
+				if err := consumeCallbackToken(store, *token); err != nil {
+					slog.Error("Failed deleting used callback token", "error", err)
 				}
 			}
+			answerCallback(bot, update.CallbackQuery.ID, reply)
 		}
 	}
 }
 
 func updateMessages(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, store store.Store) {
 	for {
-		// Set current time so we know when messages are to old to remove
-		currentTime := time.Now()
-
-		// Get all our entries
-		entries, err := store.GetEntries()
-		if err != nil {
-			slog.Error("Failed getting saved entries", "error", err)
-		}
+		// The first sync happens on startup, so wait before checking again
+		time.Sleep(cleanupInterval())
 
-		for _, entry := range entries {
-			if currentTime.Sub(entry.SentTime).Hours() < 48 {
+		summary := syncMessages(bot, chatID, secret, rss, store, time.Now(), true)
+		slog.Debug("Sync finished", summary.logAttrs()...)
 
-				// We can edit the message!
-				minifluxEntry, err := rss.Entry(entry.ID)
-				if err != nil {
-					slog.Error("Failed getting Miniflux entry", "error", err)
-					continue
-				}
-
-				// If we're read, were updated > 2 hours ago and set to delete it, cleanup the message
-				if (minifluxEntry.Status == "read") && (currentTime.Sub(minifluxEntry.ChangedAt).Hours() > 2) && entry.DeleteRead {
-					_, err := bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, entry.TelegramID))
-					slog.Info("Deleting message for read entry", "entry", entry.ID)
-					if err != nil {
-						slog.Error("Failed deleting message in Telegram", "error", err)
-					}
-					// Cleanup entry in DB
-					err = store.DeleteEntryByID(entry.ID)
-					if err != nil {
-						slog.Error("Error deleting entry in storage", "error", err)
-					}
-				} else if minifluxEntry.ChangedAt.Truncate(time.Second).After(entry.UpdatedTime) {
-					// If entry has been updated in Miniflux (marked as read, starred etc) update Telegram keyboard
-					// Note: We're required to truncate Miniflux's time since it stores it down to the millisecond which the bot doesn't
-					// Without truncating it its always seen as "after" so we constantly update
-					slog.Info("Updating keyboard for entry", "entry", entry.ID)
-					updateKeyboard(bot, chatID, secret, rss, entry.TelegramID, entry.ID)
-					err := store.UpdateEntryTime(entry.ID, minifluxEntry.ChangedAt)
-					if err != nil {
-						slog.Error("Failed updating entry in storage", "error", err)
-					}
-				}
-			} else {
-				// Cleanup the DB entry since there is nothing we can do with it
-				if err := store.DeleteEntryByID(entry.ID); err != nil {
-					slog.Error("Error deleting entry in storage", "error", err)
-				} else {
-					slog.Info("Cleaned up old entry in storage", "entry", entry.ID)
-				}
+		// Pick up entries starred or unstarred in Miniflux
+		if pinStarredEnabled() {
+			if _, _, err := syncAllPins(bot, chatID, secret, rss, store, false); err != nil {
+				slog.Error("Failed syncing pins", "error", err)
 			}
 		}
-
-		// Sleep for 10 minutes until we check again
-		time.Sleep(time.Duration(10 * time.Minute))
 	}
 }
 
@@ -310,20 +404,19 @@ func sendText(bot *tgbotapi.BotAPI, chatID int64, msgStr string, silentMessage b
 }
 
 func sendMsg(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, entry *miniflux.Entry, silentMessage bool, deleteRead bool, store store.Store) error {
-	msg := tgbotapi.NewMessage(chatID,
-		fmt.Sprintf("*%s*\n%s in %s\n%s",
-			escapeText("ModeMarkdownV2", entry.Title),
-			escapeText("ModeMarkdownV2", entry.Feed.Title),
-			escapeText("ModeMarkdownV2", entry.Feed.Category.Title),
-			escapeText("ModeMarkdownV2", entry.URL),
-		),
-	)
-	msg.ReplyMarkup = generateKeyboard(entry, secret)
+	_, err := sendEntry(bot, chatID, secret, entry, silentMessage, deleteRead, store)
+	return err
+}
+
+// sendEntry sends an entry's message and saves it, returning the message's ID
+func sendEntry(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, entry *miniflux.Entry, silentMessage bool, deleteRead bool, store store.Store) (int, error) {
+	msg := tgbotapi.NewMessage(chatID, formatEntry(entry))
+	msg.ReplyMarkup = generateKeyboard(entry, secret, loadEntryExtras(store, entry.ID))
 	msg.ParseMode = "MarkdownV2"
 	msg.DisableNotification = silentMessage
 	message, err := bot.Send(msg)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Save our message
@@ -333,44 +426,64 @@ func sendMsg(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, en
 	messageEntry.SentTime = message.Time()
 	messageEntry.UpdatedTime = entry.ChangedAt
 	messageEntry.DeleteRead = deleteRead
+	messageEntry.Fingerprint = entryFingerprint(entry)
 	if err := store.InsertEntry(messageEntry); err != nil {
-		return err
+		return message.MessageID, err
+	}
+
+	// Snoozed entries can already be starred
+	if entry.Starred {
+		if err := syncPin(bot, chatID, store, entry, message.MessageID); err != nil {
+			slog.Error("Failed pinning message", "error", err, "entry", entry.ID)
+		}
 	}
 
-	return nil
+	return message.MessageID, nil
 }
 
-func generateKeyboard(entry *miniflux.Entry, secret types.TelegramSecret) tgbotapi.InlineKeyboardMarkup {
-	buttons := make(map[string]string)
+// formatEntry renders an entry as MarkdownV2 message text
+func formatEntry(entry *miniflux.Entry) string {
+	return fmt.Sprintf("*%s*\n%s in %s\n%s",
+		escapeText("ModeMarkdownV2", entry.Title),
+		escapeText("ModeMarkdownV2", entry.Feed.Title),
+		escapeText("ModeMarkdownV2", entry.Feed.Category.Title),
+		escapeText("ModeMarkdownV2", entry.URL),
+	)
+}
 
-	if entry.Starred {
-		buttons["star"] = "Unstar"
-	} else {
-		buttons["star"] = "Star"
-	}
-	buttons["star_data"] = fmt.Sprintf("%s:%v:%v", secret, star, entry.ID)
+// legacyActions maps the action names sent by older versions to their short codes.
+// Only the actions released before callback data was signed can be in old keyboards.
+var legacyActions = map[string]string{
+	"markRead":      markRead,
+	"markUnread":    markUnread,
+	"deleteAndMark": deleteAndMark,
+	"deleteMessage": deleteMessage,
+	"star":          star,
+}
 
-	if entry.Status == "unread" {
-		buttons["unread"] = "Mark as read"
-		buttons["unread_data"] = fmt.Sprintf("%s:%v:%v", secret, markRead, entry.ID)
-	} else {
-		buttons["unread"] = "Mark as unread"
-		buttons["unread_data"] = fmt.Sprintf("%s:%v:%v", secret, markUnread, entry.ID)
+// decodeCallback verifies callback data, falling back to the legacy format so
+// keyboards sent before an upgrade keep working until they're redrawn
+func decodeCallback(secret types.TelegramSecret, raw string) (callback.Data, error) {
+	data, err := callback.Decode(secret, raw)
+	if err == nil {
+		return data, nil
+	}
+	legacy, legacyErr := callback.DecodeLegacy(secret, raw)
+	if legacyErr != nil {
+		return data, err
 	}
+	action, ok := legacyActions[legacy.Action]
+	if !ok {
+		return data, err
+	}
+	slog.Debug("Received callback in the legacy format", "action", legacy.Action)
+	legacy.Action = action
+	return legacy, nil
+}
 
-	buttons["deleteAndMark"] = fmt.Sprintf("%s:%v:%v", secret, deleteAndMark, entry.ID)
-
-	markup := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(buttons["unread"], buttons["unread_data"]),
-			tgbotapi.NewInlineKeyboardButtonData(buttons["star"], buttons["star_data"]),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Delete message", fmt.Sprintf("%s:%v", secret, deleteMessage)),
-			tgbotapi.NewInlineKeyboardButtonData("Delete & mark as read", buttons["deleteAndMark"]),
-		),
-	)
-	return markup
+// callbackData encodes a signed action for an inline keyboard button
+func callbackData(secret types.TelegramSecret, action string, entryID int64, param string) string {
+	return callback.Encode(secret, callback.Data{Action: action, EntryID: entryID, Param: param})
 }
 
 func answerCallback(bot *tgbotapi.BotAPI, queryID string, reply string) {
@@ -382,20 +495,30 @@ func answerCallback(bot *tgbotapi.BotAPI, queryID string, reply string) {
 	)
 }
 
-func updateKeyboard(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, messageID int, entry int64) {
+func updateKeyboard(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, store store.Store, messageID int, entry int64) {
 	// Get latest entry data
 	entryData, err := rss.Entry(entry)
 	if err != nil {
 		slog.Error("Error updating keyboard", "error", err)
+		return
 	}
+	setKeyboard(bot, chatID, secret, store, messageID, entryData)
 
-	// Generate new keyboard data
+	// Starring and unstarring both end up here, so keep the pin in step
+	if err := syncPin(bot, chatID, store, entryData, messageID); err != nil {
+		slog.Error("Failed updating pin", "error", err, "entry", entry)
+	}
+}
+
+// setKeyboard replaces a message's keyboard using entry data we've already fetched
+func setKeyboard(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, store store.Store, messageID int, entry *miniflux.Entry) error {
 	msg := tgbotapi.NewEditMessageReplyMarkup(
 		chatID,
 		messageID,
-		generateKeyboard(entryData, secret),
+		generateKeyboard(entry, secret, loadEntryExtras(store, entry.ID)),
 	)
-	bot.Send(msg)
+	_, err := bot.Send(msg)
+	return err
 }
 
 // EscapeText takes an input text and escape Telegram markup symbols.
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"expvar"
+	"log/slog"
+	"net/http"
+)
+
+// Outcomes of the sync loop, counted so it's easy to see what it's been doing
+const (
+	outcomeReadDeleted      string = "read_deleted"
+	outcomeReadCollapsed    string = "read_collapsed"
+	outcomeKeyboardUpdated  string = "keyboard_updated"
+	outcomeTextUpdated      string = "text_updated"
+	outcomeExpiringStripped string = "expiring_stripped"
+	outcomeTooOld           string = "too_old"
+	outcomeEntryGoneDeleted string = "entry_gone_deleted"
+	outcomeEntryGoneStubbed string = "entry_gone_stubbed"
+	outcomeMessageGone      string = "message_gone"
+	outcomeTelegramError    string = "telegram_error"
+	outcomeMinifluxError    string = "miniflux_error"
+	outcomeStorageError     string = "storage_error"
+)
+
+var syncOutcomes = expvar.NewMap("sync_outcomes")
+
+// recordOutcome counts an outcome of the sync loop
+func recordOutcome(outcome string) {
+	syncOutcomes.Add(outcome, 1)
+}
+
+// serveMetrics serves our counters as JSON on /debug/vars
+func serveMetrics(addr string) {
+	slog.Info("Serving metrics", "address", addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		slog.Error("Failed serving metrics", "error", err)
+	}
+}
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY NOT NULL,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS telegraph_pages (
+	entry_id INTEGER PRIMARY KEY NOT NULL,
+	path TEXT NOT NULL,
+	url TEXT NOT NULL,
+	created TEXT NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE telegraph_pages;
+DROP TABLE settings;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS snoozes (
+	entry_id INTEGER PRIMARY KEY NOT NULL,
+	until TEXT NOT NULL,
+	delete_read BOOLEAN DEFAULT true NOT NULL
+);
+CREATE INDEX IF NOT EXISTS snoozes_until ON snoozes(until);
+
+-- +goose Down
+DROP TABLE snoozes;
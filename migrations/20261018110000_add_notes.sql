@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS notes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	entry_id INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	created TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS notes_entry_id ON notes(entry_id);
+
+-- +goose Down
+DROP TABLE notes;
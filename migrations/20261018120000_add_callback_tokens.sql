@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS callback_tokens (
+	token TEXT PRIMARY KEY NOT NULL,
+	action TEXT NOT NULL,
+	entry_ids TEXT NOT NULL,
+	page INTEGER DEFAULT 0 NOT NULL,
+	chat_id INTEGER NOT NULL,
+	param TEXT DEFAULT '' NOT NULL,
+	single_use BOOLEAN DEFAULT false NOT NULL,
+	expires TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS callback_tokens_expires ON callback_tokens(expires);
+
+-- +goose Down
+DROP TABLE callback_tokens;
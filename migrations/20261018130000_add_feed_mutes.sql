@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS feed_mutes (
+	feed_id INTEGER PRIMARY KEY NOT NULL,
+	feed_title TEXT NOT NULL,
+	until TEXT
+);
+
+-- +goose Down
+DROP TABLE feed_mutes;
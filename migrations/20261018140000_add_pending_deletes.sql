@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS pending_deletes (
+	telegram_id INTEGER PRIMARY KEY NOT NULL,
+	entry_id INTEGER NOT NULL,
+	sent_time TEXT NOT NULL,
+	updated TEXT NOT NULL,
+	delete_read BOOLEAN DEFAULT true NOT NULL,
+	marked_read BOOLEAN DEFAULT false NOT NULL,
+	expires TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pending_deletes_expires ON pending_deletes(expires);
+
+-- +goose Down
+DROP TABLE pending_deletes;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS selected_entries (
+	entry_id INTEGER PRIMARY KEY NOT NULL,
+	telegram_id INTEGER NOT NULL,
+	added TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE selected_entries;
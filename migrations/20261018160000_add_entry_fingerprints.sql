@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE entries ADD COLUMN fingerprint TEXT DEFAULT '' NOT NULL;
+
+-- +goose Down
+ALTER TABLE entries DROP COLUMN fingerprint;
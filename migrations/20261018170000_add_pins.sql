@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS pins (
+	entry_id INTEGER PRIMARY KEY NOT NULL,
+	telegram_id INTEGER NOT NULL,
+	pinned TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE pins;
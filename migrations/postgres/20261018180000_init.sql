@@ -0,0 +1,85 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS entries (
+	id BIGINT PRIMARY KEY NOT NULL,
+	telegram_id INTEGER NOT NULL,
+	sent_time TIMESTAMPTZ NOT NULL,
+	updated TIMESTAMPTZ NOT NULL,
+	delete_read BOOLEAN DEFAULT true NOT NULL,
+	fingerprint TEXT DEFAULT '' NOT NULL
+);
+CREATE INDEX IF NOT EXISTS entries_telegram_id ON entries(telegram_id);
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY NOT NULL,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS telegraph_pages (
+	entry_id BIGINT PRIMARY KEY NOT NULL,
+	path TEXT NOT NULL,
+	url TEXT NOT NULL,
+	created TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS snoozes (
+	entry_id BIGINT PRIMARY KEY NOT NULL,
+	until TIMESTAMPTZ NOT NULL,
+	delete_read BOOLEAN DEFAULT true NOT NULL
+);
+CREATE INDEX IF NOT EXISTS snoozes_until ON snoozes(until);
+CREATE TABLE IF NOT EXISTS notes (
+	id BIGSERIAL PRIMARY KEY,
+	entry_id BIGINT NOT NULL,
+	text TEXT NOT NULL,
+	created TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS notes_entry_id ON notes(entry_id);
+CREATE TABLE IF NOT EXISTS callback_tokens (
+	token TEXT PRIMARY KEY NOT NULL,
+	action TEXT NOT NULL,
+	entry_ids BIGINT[] NOT NULL,
+	page INTEGER DEFAULT 0 NOT NULL,
+	chat_id BIGINT NOT NULL,
+	param TEXT DEFAULT '' NOT NULL,
+	single_use BOOLEAN DEFAULT false NOT NULL,
+	expires TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS callback_tokens_expires ON callback_tokens(expires);
+CREATE TABLE IF NOT EXISTS feed_mutes (
+	feed_id BIGINT PRIMARY KEY NOT NULL,
+	feed_title TEXT NOT NULL,
+	until TIMESTAMPTZ
+);
+CREATE TABLE IF NOT EXISTS pending_deletes (
+	telegram_id INTEGER PRIMARY KEY NOT NULL,
+	entry_id BIGINT NOT NULL,
+	sent_time TIMESTAMPTZ NOT NULL,
+	updated TIMESTAMPTZ NOT NULL,
+	delete_read BOOLEAN DEFAULT true NOT NULL,
+	marked_read BOOLEAN DEFAULT false NOT NULL,
+	expires TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pending_deletes_expires ON pending_deletes(expires);
+CREATE TABLE IF NOT EXISTS selected_entries (
+	entry_id BIGINT PRIMARY KEY NOT NULL,
+	telegram_id INTEGER NOT NULL,
+	added TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS pins (
+	entry_id BIGINT PRIMARY KEY NOT NULL,
+	telegram_id INTEGER NOT NULL,
+	pinned TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE pins;
+DROP TABLE selected_entries;
+DROP TABLE pending_deletes;
+DROP TABLE feed_mutes;
+DROP TABLE callback_tokens;
+DROP TABLE notes;
+DROP TABLE snoozes;
+DROP TABLE telegraph_pages;
+DROP TABLE settings;
+DROP TABLE entries;
+-- +goose StatementEnd
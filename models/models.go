@@ -11,4 +11,68 @@ type Message struct {
 	SentTime    time.Time // The time the message was sent
 	UpdatedTime time.Time // The time the message was last updated
 	DeleteRead  bool      // Delete when the entry has been read for X time
+	Fingerprint string    // Hash of the message text, to spot when the entry changes
+}
+
+// TelegraphPage is a Telegraph page published for an entry
+type TelegraphPage struct {
+	EntryID int64     // Miniflux's entry ID
+	Path    string    // The path of the page on Telegraph
+	URL     string    // The full URL of the page
+	Created time.Time // The time the page was published
+}
+
+// Snooze is an entry waiting to be sent again later
+type Snooze struct {
+	EntryID    int64     // Miniflux's entry ID
+	Until      time.Time // When the entry should be sent again
+	DeleteRead bool      // Delete when the entry has been read for X time
+}
+
+// FeedMute is a feed whose new entries aren't being sent
+type FeedMute struct {
+	FeedID    int64     // Miniflux's feed ID
+	FeedTitle string    // Title of the feed when it was muted
+	Until     time.Time // When the mute ends, zero if it's muted forever
+}
+
+// Note is text attached to an entry by replying to its message
+type Note struct {
+	ID      int64     // Auto-incrementing note ID
+	EntryID int64     // Miniflux's entry ID
+	Text    string    // The text of the note
+	Created time.Time // The time the note was added
+}
+
+// CallbackToken is the stored payload for a button whose state doesn't fit in Telegram's callback data
+type CallbackToken struct {
+	Token     string    // Random token sent as the button's callback data
+	Action    string    // The action to run when the button is pressed
+	EntryIDs  []int64   // Miniflux entry IDs the action applies to
+	Page      int       // Page to show, for paginated messages
+	ChatID    int64     // The chat the button was sent to
+	Param     string    // Any extra parameter for the action
+	SingleUse bool      // Delete the token once the button has been pressed
+	Expires   time.Time // The time the token stops working
+}
+
+// PendingDelete is an entry message that's been deleted but can still be restored
+type PendingDelete struct {
+	Entry      Message   // The entry's stored message, restored if the delete is undone
+	MarkedRead bool      // Whether the entry was marked as read when it was deleted
+	Expires    time.Time // The time the message is deleted for good
+}
+
+// Selection is an entry message selected for a bulk action
+type Selection struct {
+	EntryID    int64     // Miniflux's entry ID
+	TelegramID int       // The entry's message ID in Telegram
+	Added      time.Time // The time the entry was selected
+}
+
+// Pin is an entry message pinned because the entry is starred
+type Pin struct {
+	EntryID    int64     // Miniflux's entry ID
+	TelegramID int       // The pinned message's ID in Telegram
+	Pinned     time.Time // The time the message was pinned
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// Options offered when muting a feed
+const (
+	muteDay     string = "1d"
+	muteWeek    string = "1w"
+	muteForever string = "forever"
+)
+
+// muteUntil works out when a feed mute should end, a zero time means it never does
+func muteUntil(option string, now time.Time) (time.Time, error) {
+	switch option {
+	case muteDay:
+		return now.AddDate(0, 0, 1), nil
+	case muteWeek:
+		return now.AddDate(0, 0, 7), nil
+	case muteForever:
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("unknown mute option %q", option)
+}
+
+// muteKeyboard lets the user pick how long to mute an entry's feed for
+func muteKeyboard(secret types.TelegramSecret, entryID int64) tgbotapi.InlineKeyboardMarkup {
+	option := func(label string, option string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(label, callbackData(secret, muteFeed, entryID, option))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			option("1 day", muteDay),
+			option("1 week", muteWeek),
+			option("Forever", muteForever),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			// Cancelling puts the entry's keyboard back, the same as for snoozing
+			tgbotapi.NewInlineKeyboardButtonData("Cancel", callbackData(secret, cancelLater, entryID, "")),
+		),
+	)
+}
+
+// showMuteOptions swaps an entry's keyboard for the mute options
+func showMuteOptions(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, messageID int, entryID int64) error {
+	_, err := bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, muteKeyboard(secret, entryID)))
+	return err
+}
+
+// muteEntryFeed mutes the feed an entry belongs to
+func muteEntryFeed(rss *miniflux.Client, store store.Store, entryID int64, until time.Time) (models.FeedMute, error) {
+	entry, err := rss.Entry(entryID)
+	if err != nil {
+		return models.FeedMute{}, err
+	}
+
+	mute := models.FeedMute{
+		FeedID:    entry.FeedID,
+		FeedTitle: fmt.Sprintf("Feed #%d", entry.FeedID),
+		Until:     until,
+	}
+	if entry.Feed != nil {
+		mute.FeedTitle = entry.Feed.Title
+	}
+	return mute, store.InsertFeedMute(mute)
+}
+
+// describeMute says how long a feed is muted for
+func describeMute(mute models.FeedMute) string {
+	if mute.Until.IsZero() {
+		return fmt.Sprintf("%s is muted", mute.FeedTitle)
+	}
+	return fmt.Sprintf("%s is muted until %s", mute.FeedTitle, mute.Until.Format("Mon 2 Jan 15:04"))
+}
+
+// mutedFeeds returns the IDs of feeds which are currently muted
+func mutedFeeds(store store.Store, now time.Time) map[int64]bool {
+	muted := make(map[int64]bool)
+	mutes, err := store.GetFeedMutes(now)
+	if err != nil {
+		slog.Error("Failed getting muted feeds", "error", err)
+		return muted
+	}
+	for _, mute := range mutes {
+		muted[mute.FeedID] = true
+	}
+	return muted
+}
+
+// mutedMessage lists muted feeds with a button to unmute each of them
+func mutedMessage(store store.Store, secret types.TelegramSecret) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	mutes, err := store.GetFeedMutes(time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	if len(mutes) == 0 {
+		return "No feeds are muted. Use the Mute feed button on an entry to mute its feed.", nil, nil
+	}
+
+	var text strings.Builder
+	keyboard := tgbotapi.NewInlineKeyboardMarkup()
+	for _, mute := range mutes {
+		fmt.Fprintf(&text, "%s\n", describeMute(mute))
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Unmute "+mute.FeedTitle, callbackData(secret, unmuteFeed, mute.FeedID, "")),
+		))
+	}
+	return truncateMessage(text.String()), &keyboard, nil
+}
+
+// unmute removes a feed's mute and refreshes the /muted message it was removed from
+func unmute(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, store store.Store, messageID int, feedID int64) error {
+	if err := store.DeleteFeedMute(feedID); err != nil {
+		return err
+	}
+
+	text, keyboard, err := mutedMessage(store, secret)
+	if err != nil {
+		return err
+	}
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	msg.ReplyMarkup = keyboard
+	_, err = bot.Send(msg)
+	return err
+}
+
+func mutedCommand(ctx commandContext) error {
+	text, keyboard, err := mutedMessage(ctx.store, ctx.secret)
+	if err != nil {
+		return err
+	}
+	return ctx.replyWithKeyboard(text, keyboard)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.jloh.dev/miniflux-telegram-bot/models"
+)
+
+func TestMuteUntil(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+	var tests = []struct {
+		option      string
+		expected    time.Time
+		explanation string
+	}{
+		{muteDay, time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC), "A day from now"},
+		{muteWeek, time.Date(2026, 10, 25, 9, 30, 0, 0, time.UTC), "A week from now"},
+		{muteForever, time.Time{}, "Forever has no end time"},
+	}
+
+	for _, tt := range tests {
+		until, err := muteUntil(tt.option, now)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.explanation, err)
+		}
+		if !until.Equal(tt.expected) {
+			t.Errorf("%s: got %v, want %v", tt.explanation, until, tt.expected)
+		}
+	}
+
+	if _, err := muteUntil("fortnight", now); err == nil {
+		t.Error("Expected an error for an unknown option")
+	}
+}
+
+func TestDescribeMute(t *testing.T) {
+	var tests = []struct {
+		mute     models.FeedMute
+		expected string
+	}{
+		{models.FeedMute{FeedTitle: "Hacker News"}, "Hacker News is muted"},
+		{models.FeedMute{FeedTitle: "Hacker News", Until: time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)}, "Hacker News is muted until Mon 19 Oct 09:30"},
+	}
+
+	for _, tt := range tests {
+		if description := describeMute(tt.mute); description != tt.expected {
+			t.Errorf("got %q, want %q", description, tt.expected)
+		}
+	}
+}
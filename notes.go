@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// isReplyToBot checks whether a message is a reply to one of our own messages
+func isReplyToBot(bot *tgbotapi.BotAPI, message *tgbotapi.Message) bool {
+	return message.ReplyToMessage != nil && message.ReplyToMessage.From != nil && message.ReplyToMessage.From.ID == bot.Self.ID
+}
+
+// isInEntryChat checks whether a message was sent in the chat we send entries to.
+// Telegram numbers messages per chat, so a reply anywhere else can't refer to our entries.
+func isInEntryChat(message *tgbotapi.Message, chatID int64) bool {
+	return message.Chat != nil && message.Chat.ID == chatID
+}
+
+// replyText sends a plain text message as a reply to another message
+func replyText(bot *tgbotapi.BotAPI, chatID int64, replyTo int, text string) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyToMessageID = replyTo
+	msg.DisableNotification = true
+	_, err := bot.Send(msg)
+	return err
+}
+
+// saveNote attaches the text of a reply to the entry of the message it replied to
+func saveNote(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, s store.Store, message *tgbotapi.Message) error {
+	if !isInEntryChat(message, chatID) {
+		return nil
+	}
+	entry, err := s.GetEntryByTelegramID(message.ReplyToMessage.MessageID)
+	if errors.Is(err, store.ErrNotFound) {
+		// Replies to our other messages, like the dashboard or "Note saved", aren't notes
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	note := models.Note{
+		EntryID: entry.ID,
+		Text:    message.Text,
+		Created: time.Now(),
+	}
+	if err := s.InsertNote(note); err != nil {
+		return err
+	}
+
+	go updateKeyboard(bot, chatID, secret, rss, s, entry.TelegramID, entry.ID)
+	return replyText(bot, chatID, message.MessageID, "Note saved")
+}
+
+// sendEntryNotes replies to an entry message with the notes attached to it
+func sendEntryNotes(bot *tgbotapi.BotAPI, chatID int64, store store.Store, replyTo int, entryID int64) error {
+	notes, err := store.GetNotesByEntryID(entryID)
+	if err != nil {
+		return err
+	}
+	if len(notes) == 0 {
+		return replyText(bot, chatID, replyTo, "This entry has no notes")
+	}
+
+	var text strings.Builder
+	for _, note := range notes {
+		fmt.Fprintf(&text, "%s: %s\n", note.Created.Format("2006-01-02 15:04"), note.Text)
+	}
+	return replyText(bot, chatID, replyTo, truncateMessage(text.String()))
+}
+
+// groupNotes groups notes by entry, keeping the order they were returned in
+func groupNotes(notes []models.Note) ([]int64, map[int64][]models.Note) {
+	order := make([]int64, 0)
+	grouped := make(map[int64][]models.Note)
+	for _, note := range notes {
+		if _, exists := grouped[note.EntryID]; !exists {
+			order = append(order, note.EntryID)
+		}
+		grouped[note.EntryID] = append(grouped[note.EntryID], note)
+	}
+	return order, grouped
+}
+
+// entryTitle looks up an entry's title and URL, falling back to its ID if Miniflux no longer has it
+func entryTitle(rss *miniflux.Client, entryID int64) (string, string) {
+	entry, err := rss.Entry(entryID)
+	if err != nil {
+		return fmt.Sprintf("Entry #%d", entryID), ""
+	}
+	return entry.Title, entry.URL
+}
+
+// formatNotesExport renders all notes as a Markdown document
+func formatNotesExport(rss *miniflux.Client, notes []models.Note) string {
+	order, grouped := groupNotes(notes)
+
+	var export strings.Builder
+	export.WriteString("# Miniflux notes\n")
+	for _, entryID := range order {
+		title, url := entryTitle(rss, entryID)
+		fmt.Fprintf(&export, "\n## %s\n", title)
+		if url != "" {
+			fmt.Fprintf(&export, "%s\n", url)
+		}
+		export.WriteString("\n")
+		for _, note := range grouped[entryID] {
+			fmt.Fprintf(&export, "- %s: %s\n", note.Created.Format("2006-01-02 15:04"), note.Text)
+		}
+	}
+	return export.String()
+}
+
+// truncateMessage shortens text to fit in a single Telegram message
+func truncateMessage(text string) string {
+	if len(text) <= maxMessageLength {
+		return text
+	}
+	suffix := "\n…"
+	cut := maxMessageLength - len(suffix)
+	// Don't cut a multi-byte character in half
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + suffix
+}
+
+func notesCommand(ctx commandContext) error {
+	notes, err := ctx.store.GetNotes()
+	if err != nil {
+		return err
+	}
+	if len(notes) == 0 {
+		return ctx.reply("You haven't added any notes yet. Reply to an entry message to add one.")
+	}
+
+	if strings.TrimSpace(ctx.args) == "export" {
+		doc := tgbotapi.NewDocumentUpload(ctx.chatID, tgbotapi.FileBytes{
+			Name:  fmt.Sprintf("miniflux-notes-%s.md", time.Now().Format("2006-01-02")),
+			Bytes: []byte(formatNotesExport(ctx.rss, notes)),
+		})
+		_, err := ctx.bot.Send(doc)
+		return err
+	}
+
+	order, grouped := groupNotes(notes)
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d annotated entries:\n", len(order))
+	for _, entryID := range order {
+		title, _ := entryTitle(ctx.rss, entryID)
+		fmt.Fprintf(&text, "\n%s\n", title)
+		for _, note := range grouped[entryID] {
+			fmt.Fprintf(&text, "  • %s\n", note.Text)
+		}
+	}
+	text.WriteString("\nSend /notes export to download them all.")
+	return ctx.reply(truncateMessage(text.String()))
+}
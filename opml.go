@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"go.jloh.dev/miniflux-telegram-bot/parse"
+	miniflux "miniflux.app/client"
+)
+
+// Telegram only lets bots download files up to 20MB
+const maxOPMLSize = 20 * 1024 * 1024
+
+// isOPMLDocument checks whether an uploaded document looks like an OPML file
+func isOPMLDocument(document *tgbotapi.Document) bool {
+	return document != nil && strings.HasSuffix(strings.ToLower(document.FileName), ".opml")
+}
+
+// exportOPML sends Miniflux's OPML export to the chat as a document
+func exportOPML(bot *tgbotapi.BotAPI, chatID int64, rss *miniflux.Client) error {
+	opml, err := rss.Export()
+	if err != nil {
+		return err
+	}
+
+	doc := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("miniflux-%s.opml", time.Now().Format("2006-01-02")),
+		Bytes: opml,
+	})
+	_, err = bot.Send(doc)
+	return err
+}
+
+// importOPML downloads an uploaded OPML document from Telegram, imports it
+// into Miniflux and returns a summary of what changed
+func importOPML(bot *tgbotapi.BotAPI, rss *miniflux.Client, document *tgbotapi.Document) (string, error) {
+	fileURL, err := bot.GetFileDirectURL(document.FileID)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Get(fileURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status downloading file: %s", resp.Status)
+	}
+
+	opml, err := io.ReadAll(io.LimitReader(resp.Body, maxOPMLSize))
+	if err != nil {
+		return "", err
+	}
+
+	feedURLs, err := parse.OPMLFeedURLs(opml)
+	if err != nil {
+		return "", err
+	}
+
+	// Record which feeds already exist so we can report what the import added
+	existingFeeds, err := rss.Feeds()
+	if err != nil {
+		return "", err
+	}
+	existing := make(map[string]bool, len(existingFeeds))
+	for _, feed := range existingFeeds {
+		existing[feed.FeedURL] = true
+	}
+
+	if err := rss.Import(io.NopCloser(bytes.NewReader(opml))); err != nil {
+		return "", err
+	}
+
+	importedFeeds, err := rss.Feeds()
+	if err != nil {
+		return "", err
+	}
+	imported := make(map[string]bool, len(importedFeeds))
+	for _, feed := range importedFeeds {
+		imported[feed.FeedURL] = true
+	}
+
+	var added, present int
+	for _, feedURL := range feedURLs {
+		if existing[feedURL] {
+			present++
+		} else if imported[feedURL] {
+			added++
+		}
+	}
+
+	return fmt.Sprintf("Imported %s: %d feeds added, %d already present", document.FileName, added, present), nil
+}
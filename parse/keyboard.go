@@ -0,0 +1,57 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyboardLayout parses a keyboard layout where rows are separated by
+// semicolons and the buttons in each row by commas, e.g. "read,star;delete".
+// Every button must be one of known.
+func KeyboardLayout(layout string, known []string) ([][]string, error) {
+	isKnown := make(map[string]bool, len(known))
+	for _, button := range known {
+		isKnown[button] = true
+	}
+
+	rows := make([][]string, 0)
+	for _, row := range strings.Split(layout, ";") {
+		buttons := make([]string, 0)
+		for _, button := range strings.Split(row, ",") {
+			button = strings.ToLower(strings.TrimSpace(button))
+			if button == "" {
+				continue
+			}
+			if !isKnown[button] {
+				return nil, fmt.Errorf("Unknown keyboard button %q", button)
+			}
+			buttons = append(buttons, button)
+		}
+		if len(buttons) > 0 {
+			rows = append(rows, buttons)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("Keyboard layout %q has no buttons", layout)
+	}
+	return rows, nil
+}
+
+// KeyboardLabels parses button labels in the form "read=✅ Read;star=⭐ Star"
+func KeyboardLabels(labels string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(labels, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		button, label, found := strings.Cut(pair, "=")
+		button = strings.ToLower(strings.TrimSpace(button))
+		label = strings.TrimSpace(label)
+		if !found || button == "" || label == "" {
+			return nil, fmt.Errorf("Invalid keyboard label %q, expected button=label", pair)
+		}
+		result[button] = label
+	}
+	return result, nil
+}
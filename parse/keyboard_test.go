@@ -0,0 +1,99 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeyboardLayout(t *testing.T) {
+	known := []string{"read", "star", "delete", "later"}
+	var tests = []struct {
+		explanation   string
+		layout        string
+		expected      [][]string
+		validExpected bool
+	}{
+		{
+			"Rows and buttons are split",
+			"read,star;delete",
+			[][]string{{"read", "star"}, {"delete"}},
+			true,
+		}, {
+			"Whitespace and case are ignored",
+			" Read , STAR ; later ",
+			[][]string{{"read", "star"}, {"later"}},
+			true,
+		}, {
+			"Empty rows are dropped",
+			"read;;star;",
+			[][]string{{"read"}, {"star"}},
+			true,
+		}, {
+			"Unknown button is invalid",
+			"read,share",
+			nil,
+			false,
+		}, {
+			"Empty layout is invalid",
+			" ; ",
+			nil,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		layout, err := KeyboardLayout(tt.layout, known)
+		if (err == nil) != tt.validExpected {
+			t.Errorf("%s: input [%s], got %v, want %v", tt.explanation, tt.layout, err, tt.validExpected)
+		}
+		if !reflect.DeepEqual(layout, tt.expected) {
+			t.Errorf("%s: input [%s], got %v, want %v", tt.explanation, tt.layout, layout, tt.expected)
+		}
+	}
+}
+
+func TestKeyboardLabels(t *testing.T) {
+	var tests = []struct {
+		explanation   string
+		labels        string
+		expected      map[string]string
+		validExpected bool
+	}{
+		{
+			"Labels are split into a map",
+			"read=✅ Read; star = ⭐",
+			map[string]string{"read": "✅ Read", "star": "⭐"},
+			true,
+		}, {
+			"Labels can contain equals signs",
+			"read=a=b",
+			map[string]string{"read": "a=b"},
+			true,
+		}, {
+			"Empty string has no labels",
+			"",
+			map[string]string{},
+			true,
+		}, {
+			"Missing label is invalid",
+			"read=",
+			nil,
+			false,
+		}, {
+			"Missing equals sign is invalid",
+			"read",
+			nil,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		labels, err := KeyboardLabels(tt.labels)
+		if (err == nil) != tt.validExpected {
+			t.Errorf("%s: input [%s], got %v, want %v", tt.explanation, tt.labels, err, tt.validExpected)
+		}
+		if !reflect.DeepEqual(labels, tt.expected) {
+			t.Errorf("%s: input [%s], got %v, want %v", tt.explanation, tt.labels, labels, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+)
+
+type opmlOutline struct {
+	XMLURL   string        `xml:"xmlUrl,attr"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlDocument struct {
+	XMLName  xml.Name      `xml:"opml"`
+	Outlines []opmlOutline `xml:"body>outline"`
+}
+
+// OPMLFeedURLs returns the feed URLs contained in an OPML document,
+// including any feeds nested inside category outlines
+func OPMLFeedURLs(data []byte) ([]string, error) {
+	var doc opmlDocument
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0)
+	var walk func([]opmlOutline)
+	walk = func(outlines []opmlOutline) {
+		for _, outline := range outlines {
+			if outline.XMLURL != "" {
+				urls = append(urls, outline.XMLURL)
+			}
+			walk(outline.Outlines)
+		}
+	}
+	walk(doc.Outlines)
+
+	if len(urls) == 0 {
+		return nil, errors.New("No feeds found in OPML document")
+	}
+	return urls, nil
+}
@@ -0,0 +1,58 @@
+package parse
+
+import (
+	"testing"
+)
+
+func TestOPMLFeedURLs(t *testing.T) {
+	var tests = []struct {
+		explanation   string
+		opml          string
+		expectedFeeds int
+		validExpected bool
+	}{
+		{
+			"Flat list of feeds",
+			`<?xml version="1.0" encoding="UTF-8"?>
+			<opml version="2.0"><body>
+				<outline text="One" xmlUrl="https://one.example/feed"/>
+				<outline text="Two" xmlUrl="https://two.example/feed"/>
+			</body></opml>`,
+			2,
+			true,
+		}, {
+			"Feeds nested in categories",
+			`<opml version="2.0"><body>
+				<outline text="News">
+					<outline text="One" xmlUrl="https://one.example/feed"/>
+				</outline>
+				<outline text="Tech">
+					<outline text="Two" xmlUrl="https://two.example/feed"/>
+					<outline text="Three" xmlUrl="https://three.example/feed"/>
+				</outline>
+			</body></opml>`,
+			3,
+			true,
+		}, {
+			"Document without feeds is invalid",
+			`<opml version="2.0"><body><outline text="Empty"/></body></opml>`,
+			0,
+			false,
+		}, {
+			"Non OPML document is invalid",
+			`<html><body></body></html>`,
+			0,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		urls, err := OPMLFeedURLs([]byte(tt.opml))
+		if (err == nil) != tt.validExpected {
+			t.Errorf("%s: got %v, want %v", tt.explanation, err, tt.validExpected)
+		}
+		if len(urls) != tt.expectedFeeds {
+			t.Errorf("%s: got %d feeds, want %d", tt.explanation, len(urls), tt.expectedFeeds)
+		}
+	}
+}
@@ -7,13 +7,18 @@ import (
 	"go.jloh.dev/miniflux-telegram-bot/types"
 )
 
-// We limit the size to 15 characters as callback data can only be 1-64 bytes
-var secretPattern = regexp.MustCompile("^[A-Za-z0-9]{1,15}$")
+// The secret is only used to sign callback data and never sent to Telegram,
+// so it can be any length, but short secrets are easier to guess
+const RecommendedSecretLength = 16
+
+var secretPattern = regexp.MustCompile(`^\S+$`)
 
 func TelegramSecret(secret string) (types.TelegramSecret, error) {
+	if secret == "" {
+		return "", errors.New("Secret can't be empty")
+	}
 	if !secretPattern.MatchString(secret) {
-		return "", errors.New("Invalid API key")
-
+		return "", errors.New("Secret can't contain whitespace")
 	}
 	return types.TelegramSecret(secret), nil
 }
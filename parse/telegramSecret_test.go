@@ -16,17 +16,29 @@ func TestAPIKey(t *testing.T) {
 			strings.Repeat("a", 15),
 			true,
 		}, {
-			"Colon character is invalid",
+			"Colon character is valid",
 			strings.Repeat("a", 20) + ":",
-			false,
+			true,
 		}, {
-			"Long string is invalid",
+			"Long string is valid",
 			strings.Repeat("a", 50),
-			false,
+			true,
 		}, {
 			"Randomly generated string is valid",
 			"oohue2Oob3leyah",
 			true,
+		}, {
+			"Empty string is invalid",
+			"",
+			false,
+		}, {
+			"Short string from older versions is still valid",
+			"abc123",
+			true,
+		}, {
+			"Whitespace is invalid",
+			"correct horse battery staple",
+			false,
 		},
 	}
 
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// The most messages /pins will send for starred entries that aren't in the chat any more
+const maxPinSends = 20
+
+// pinStarredEnabled checks whether starred entries should be pinned
+func pinStarredEnabled() bool {
+	return viper.GetBool("TELEGRAM_PIN_STARRED")
+}
+
+// pinnedEntries maps entries to the pins for their messages
+func pinnedEntries(s store.Store) (map[int64]models.Pin, error) {
+	pins, err := s.GetPins()
+	if err != nil {
+		return nil, err
+	}
+	pinned := make(map[int64]models.Pin, len(pins))
+	for _, pin := range pins {
+		pinned[pin.EntryID] = pin
+	}
+	return pinned, nil
+}
+
+// pinEntry pins an entry's message
+func pinEntry(bot *tgbotapi.BotAPI, chatID int64, s store.Store, entryID int64, messageID int) error {
+	_, err := bot.PinChatMessage(tgbotapi.PinChatMessageConfig{
+		ChatID:              chatID,
+		MessageID:           messageID,
+		DisableNotification: true,
+	})
+	if err != nil {
+		return err
+	}
+	return s.InsertPin(models.Pin{EntryID: entryID, TelegramID: messageID, Pinned: time.Now()})
+}
+
+// unpinEntry unpins an entry's message. The Telegram library can only unpin the
+// most recently pinned message so we call the API directly to unpin a specific one.
+func unpinEntry(bot *tgbotapi.BotAPI, chatID int64, s store.Store, pin models.Pin) error {
+	_, err := bot.MakeRequest("unpinChatMessage", url.Values{
+		"chat_id":    {strconv.FormatInt(chatID, 10)},
+		"message_id": {strconv.Itoa(pin.TelegramID)},
+	})
+	// If the message is gone there's nothing left to unpin
+	if err != nil && !isMessageGone(err) {
+		return err
+	}
+	return s.DeletePin(pin.EntryID)
+}
+
+// syncPin pins or unpins an entry's message to match whether it's starred
+func syncPin(bot *tgbotapi.BotAPI, chatID int64, s store.Store, entry *miniflux.Entry, messageID int) error {
+	if !pinStarredEnabled() {
+		return nil
+	}
+	pinned, err := pinnedEntries(s)
+	if err != nil {
+		return err
+	}
+
+	pin, isPinned := pinned[entry.ID]
+	if entry.Starred && !isPinned {
+		return pinEntry(bot, chatID, s, entry.ID, messageID)
+	}
+	if !entry.Starred && isPinned {
+		return unpinEntry(bot, chatID, s, pin)
+	}
+	return nil
+}
+
+// starredEntries gets the starred entries from Miniflux between two entry IDs, oldest first.
+// Zero IDs leave that end of the range open.
+func starredEntries(rss *miniflux.Client, afterEntryID, beforeEntryID int64) (miniflux.Entries, error) {
+	starred := make(miniflux.Entries, 0)
+	for offset := 0; ; offset += syncPageSize {
+		page, err := rss.Entries(&miniflux.Filter{
+			Starred:       miniflux.FilterOnlyStarred,
+			AfterEntryID:  afterEntryID,
+			BeforeEntryID: beforeEntryID,
+			Order:         "id",
+			Direction:     "asc",
+			Limit:         syncPageSize,
+			Offset:        offset,
+		})
+		if err != nil {
+			return starred, err
+		}
+		starred = append(starred, page.Entries...)
+		if len(page.Entries) < syncPageSize || offset+len(page.Entries) >= page.Total {
+			return starred, nil
+		}
+	}
+}
+
+// pinPlan is what syncAllPins needs to change so the pins match the starred entries
+type pinPlan struct {
+	pin   []models.Pin      // Starred entries whose messages are in the chat but not pinned
+	send  []*miniflux.Entry // Starred entries without a message, sendEntry pins them once sent
+	unpin []models.Pin      // Pins for entries which aren't starred any more
+}
+
+// planPins compares the pinned messages with the starred entries. Starred entries
+// without a message in the chat are only sent if sendMissing is set.
+func planPins(starred miniflux.Entries, pinned map[int64]models.Pin, messages map[int64]int, sendMissing bool) pinPlan {
+	var plan pinPlan
+	isStarred := make(map[int64]bool, len(starred))
+	for _, entry := range starred {
+		isStarred[entry.ID] = true
+		if _, ok := pinned[entry.ID]; ok {
+			continue
+		}
+		if messageID, ok := messages[entry.ID]; ok {
+			plan.pin = append(plan.pin, models.Pin{EntryID: entry.ID, TelegramID: messageID})
+		} else if sendMissing && len(plan.send) < maxPinSends {
+			plan.send = append(plan.send, entry)
+		}
+	}
+
+	for entryID, pin := range pinned {
+		if !isStarred[entryID] {
+			plan.unpin = append(plan.unpin, pin)
+		}
+	}
+	sort.Slice(plan.unpin, func(i, j int) bool { return plan.unpin[i].EntryID < plan.unpin[j].EntryID })
+	return plan
+}
+
+// syncAllPins makes the chat's pinned messages match Miniflux's starred entries.
+// Starred entries without a message in the chat are only sent if sendMissing is set.
+func syncAllPins(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, s store.Store, sendMissing bool) (int, int, error) {
+	pinned, err := pinnedEntries(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	tracked, err := s.GetEntries()
+	if err != nil {
+		return 0, 0, err
+	}
+	messages := make(map[int64]int, len(tracked))
+	entryIDs := make([]int64, 0, len(tracked)+len(pinned))
+	for _, message := range tracked {
+		messages[message.ID] = message.TelegramID
+		entryIDs = append(entryIDs, message.ID)
+	}
+	for entryID := range pinned {
+		entryIDs = append(entryIDs, entryID)
+	}
+
+	// Without sending missing entries only the ones with a message or pin matter,
+	// so there's no need to page through every starred entry
+	var afterEntryID, beforeEntryID int64
+	if !sendMissing {
+		if len(entryIDs) == 0 {
+			return 0, 0, nil
+		}
+		afterEntryID, beforeEntryID = slices.Min(entryIDs)-1, slices.Max(entryIDs)+1
+	}
+	starred, err := starredEntries(rss, afterEntryID, beforeEntryID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	plan := planPins(starred, pinned, messages, sendMissing)
+
+	unpinnedCount := 0
+	for _, pin := range plan.unpin {
+		if err := unpinEntry(bot, chatID, s, pin); err != nil {
+			slog.Error("Failed unpinning message", "error", err, "entry", pin.EntryID)
+			continue
+		}
+		unpinnedCount++
+	}
+
+	pinnedCount := 0
+	for _, pin := range plan.pin {
+		if err := pinEntry(bot, chatID, s, pin.EntryID, pin.TelegramID); err != nil {
+			slog.Error("Failed pinning message", "error", err, "entry", pin.EntryID)
+			continue
+		}
+		pinnedCount++
+	}
+	for _, entry := range plan.send {
+		// Starred entries are bookmarks, so they're never cleaned up once read
+		if _, err := sendEntry(bot, chatID, secret, entry, true, false, s); err != nil {
+			slog.Error("Failed sending message for starred entry", "error", err, "entry", entry.ID)
+			continue
+		}
+		pinnedCount++
+	}
+	return pinnedCount, unpinnedCount, nil
+}
+
+func pinsCommand(ctx commandContext) error {
+	if !pinStarredEnabled() {
+		return ctx.reply("Pinning starred entries is turned off. Set TELEGRAM_PIN_STARRED to turn it on.")
+	}
+	pinned, unpinned, err := syncAllPins(ctx.bot, ctx.chatID, ctx.secret, ctx.rss, ctx.store, true)
+	if err != nil {
+		return err
+	}
+	return ctx.reply(fmt.Sprintf("Pins synced with your starred entries: %d pinned, %d unpinned", pinned, unpinned))
+}
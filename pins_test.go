@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	miniflux "miniflux.app/client"
+)
+
+func TestPlanPins(t *testing.T) {
+	var tests = []struct {
+		starred     miniflux.Entries
+		pinned      map[int64]models.Pin
+		messages    map[int64]int
+		sendMissing bool
+		pin         []models.Pin
+		send        []int64
+		unpin       []models.Pin
+		explanation string
+	}{
+		{
+			miniflux.Entries{{ID: 1}},
+			map[int64]models.Pin{},
+			map[int64]int{1: 10},
+			false,
+			[]models.Pin{{EntryID: 1, TelegramID: 10}}, nil, nil,
+			"Starred entries in the chat are pinned",
+		},
+		{
+			miniflux.Entries{{ID: 1}},
+			map[int64]models.Pin{1: {EntryID: 1, TelegramID: 10}},
+			map[int64]int{1: 10},
+			false,
+			nil, nil, nil,
+			"Pinned starred entries are left alone",
+		},
+		{
+			miniflux.Entries{},
+			map[int64]models.Pin{2: {EntryID: 2, TelegramID: 20}, 1: {EntryID: 1, TelegramID: 10}},
+			map[int64]int{1: 10},
+			false,
+			nil, nil, []models.Pin{{EntryID: 1, TelegramID: 10}, {EntryID: 2, TelegramID: 20}},
+			"Unstarred entries are unpinned, even without a tracked message",
+		},
+		{
+			miniflux.Entries{{ID: 3}},
+			map[int64]models.Pin{},
+			map[int64]int{},
+			false,
+			nil, nil, nil,
+			"Starred entries without a message aren't sent by the sync loop",
+		},
+		{
+			miniflux.Entries{{ID: 3}},
+			map[int64]models.Pin{},
+			map[int64]int{},
+			true,
+			nil, []int64{3}, nil,
+			"Starred entries without a message are sent by /pins",
+		},
+	}
+
+	for _, tt := range tests {
+		plan := planPins(tt.starred, tt.pinned, tt.messages, tt.sendMissing)
+		var send []int64
+		for _, entry := range plan.send {
+			send = append(send, entry.ID)
+		}
+		if !reflect.DeepEqual(plan.pin, tt.pin) {
+			t.Errorf("%s: pin got %v, want %v", tt.explanation, plan.pin, tt.pin)
+		}
+		if !reflect.DeepEqual(send, tt.send) {
+			t.Errorf("%s: send got %v, want %v", tt.explanation, send, tt.send)
+		}
+		if !reflect.DeepEqual(plan.unpin, tt.unpin) {
+			t.Errorf("%s: unpin got %v, want %v", tt.explanation, plan.unpin, tt.unpin)
+		}
+	}
+}
+
+func TestPlanPinsLimitsSends(t *testing.T) {
+	starred := make(miniflux.Entries, 0, maxPinSends+5)
+	for i := 1; i <= maxPinSends+5; i++ {
+		starred = append(starred, &miniflux.Entry{ID: int64(i)})
+	}
+	plan := planPins(starred, map[int64]models.Pin{}, map[int64]int{}, true)
+	if len(plan.send) != maxPinSends {
+		t.Errorf("got %d sends, want %d", len(plan.send), maxPinSends)
+	}
+}
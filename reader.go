@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/article"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+const (
+	// Telegram's maximum message length
+	maxMessageLength = 4096
+	// How many articles we keep paginated in memory
+	maxCachedArticles = 20
+)
+
+// articlePages caches paginated articles so moving between pages
+// doesn't refetch the original content each time
+type articlePages struct {
+	sync.Mutex
+	pages map[int64][]string
+	order []int64
+}
+
+var articleCache = &articlePages{pages: make(map[int64][]string)}
+
+// httpClient makes our own requests outside the Miniflux and Telegram clients.
+// Updates are handled one at a time, so a hung request mustn't block them forever.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func (a *articlePages) get(entryID int64) ([]string, bool) {
+	a.Lock()
+	defer a.Unlock()
+	pages, ok := a.pages[entryID]
+	return pages, ok
+}
+
+func (a *articlePages) set(entryID int64, pages []string) {
+	a.Lock()
+	defer a.Unlock()
+	if _, exists := a.pages[entryID]; !exists {
+		a.order = append(a.order, entryID)
+	}
+	a.pages[entryID] = pages
+	// Drop the oldest articles once we're over our limit
+	for len(a.order) > maxCachedArticles {
+		delete(a.pages, a.order[0])
+		a.order = a.order[1:]
+	}
+}
+
+// fetchOriginalContent asks Miniflux to download the full article for an entry.
+// The Miniflux client doesn't support this endpoint so we call it directly.
+func fetchOriginalContent(entryID int64) (string, error) {
+	endpoint := strings.TrimSuffix(viper.GetString("MINIFLUX_URL"), "/") + fmt.Sprintf("/v1/entries/%d/fetch-content", entryID)
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Auth-Token", viper.GetString("MINIFLUX_API_KEY"))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status fetching original content: %s", resp.Status)
+	}
+
+	var result struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.Content, nil
+}
+
+// articleHeader is shown at the top of every page of an article
+func articleHeader(entry *miniflux.Entry) string {
+	title := entry.Title
+	if runes := []rune(title); len(runes) > 200 {
+		title = string(runes[:200]) + "…"
+	}
+	return fmt.Sprintf("<b>%s</b>\n\n", html.EscapeString(title))
+}
+
+// loadArticle returns the paginated full content for an entry, fetching it from Miniflux if needed
+func loadArticle(rss *miniflux.Client, entryID int64) ([]string, error) {
+	if pages, ok := articleCache.get(entryID); ok {
+		return pages, nil
+	}
+
+	entry, err := rss.Entry(entryID)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := fetchOriginalContent(entryID)
+	if err != nil || strings.TrimSpace(content) == "" {
+		// Fall back to whatever content the feed included
+		content = entry.Content
+	}
+
+	header := articleHeader(entry)
+	pages := article.Pages(content, maxMessageLength-len(header))
+	if len(pages) == 0 {
+		pages = []string{"This entry has no content to show"}
+	}
+	for i := range pages {
+		pages[i] = header + pages[i]
+	}
+
+	articleCache.set(entryID, pages)
+	return pages, nil
+}
+
+// readerKeyboard generates the Prev/Next buttons for an article page.
+// The page number goes in the signed callback data, so paging doesn't store anything.
+func readerKeyboard(secret types.TelegramSecret, entryID int64, page int, total int) tgbotapi.InlineKeyboardMarkup {
+	pageButton := func(label string, page int) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(label, callbackData(secret, readPage, entryID, strconv.Itoa(page)))
+	}
+
+	row := make([]tgbotapi.InlineKeyboardButton, 0, 3)
+	if page > 0 {
+		row = append(row, pageButton("« Prev", page-1))
+	}
+	row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, total), callbackData(secret, noop, 0, "")))
+	if page < total-1 {
+		row = append(row, pageButton("Next »", page+1))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Close", callbackData(secret, deleteMessage, 0, "")),
+		),
+	)
+}
+
+// sendArticle sends the first page of an entry's full content as a reply to its entry message
+func sendArticle(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, replyTo int, entryID int64) error {
+	pages, err := loadArticle(rss, entryID)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, pages[0])
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	msg.DisableNotification = true
+	msg.ReplyToMessageID = replyTo
+	msg.ReplyMarkup = readerKeyboard(secret, entryID, 0, len(pages))
+	_, err = bot.Send(msg)
+	return err
+}
+
+// showArticlePage edits a reader message in place to show another page
+func showArticlePage(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, messageID int, entryID int64, page int) error {
+	pages, err := loadArticle(rss, entryID)
+	if err != nil {
+		return err
+	}
+	if page < 0 || page >= len(pages) {
+		return fmt.Errorf("page %d out of range for entry %d", page, entryID)
+	}
+
+	keyboard := readerKeyboard(secret, entryID, page, len(pages))
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, pages[page])
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = &keyboard
+	_, err = bot.Send(msg)
+	return err
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// Setting keys for the bulk action message's ID and the tokens behind its buttons
+const (
+	selectionMessageSetting = "selection_message_id"
+	selectionTokensSetting  = "selection_tokens"
+)
+
+// isSelected checks whether an entry is part of the bulk action selection
+func isSelected(store store.Store, entryID int64) bool {
+	selection, err := store.GetSelection()
+	if err != nil {
+		return false
+	}
+	for _, selected := range selection {
+		if selected.EntryID == entryID {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleSelection adds or removes an entry message from the selection, returning whether it's now selected
+func toggleSelection(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, s store.Store, messageID int, entryID int64) (bool, error) {
+	selected := isSelected(s, entryID)
+	if selected {
+		if err := s.DeleteSelection(entryID); err != nil {
+			return selected, err
+		}
+	} else {
+		if err := s.InsertSelection(models.Selection{EntryID: entryID, TelegramID: messageID, Added: time.Now()}); err != nil {
+			return selected, err
+		}
+	}
+	return !selected, refreshSelectionMessage(bot, chatID, secret, s)
+}
+
+// selectionText describes how many entries are selected
+func selectionText(count int) string {
+	if count == 1 {
+		return "1 entry selected"
+	}
+	return fmt.Sprintf("%d entries selected", count)
+}
+
+// selectionKeyboard has the bulk actions for the selected entries.
+// The entry IDs are carried in callback tokens so the buttons act on
+// the selection as it was shown, even if it changes before they're pressed.
+// The tokens for the keyboard it replaces are removed.
+func selectionKeyboard(s store.Store, chatID int64, secret types.TelegramSecret, entryIDs []int64) (tgbotapi.InlineKeyboardMarkup, error) {
+	if err := deleteSelectionTokens(s); err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	tokens := make([]string, 0, 4)
+	button := func(label string, action string) (tgbotapi.InlineKeyboardButton, error) {
+		token, err := saveCallbackToken(s, models.CallbackToken{
+			Action:    action,
+			EntryIDs:  entryIDs,
+			ChatID:    chatID,
+			SingleUse: true,
+		})
+		tokens = append(tokens, token.Token)
+		return tgbotapi.NewInlineKeyboardButtonData(label, callbackData(secret, tokenAction, 0, token.Token)), err
+	}
+
+	actions := []struct {
+		label  string
+		action string
+	}{
+		{"Mark as read", bulkRead},
+		{"Star", bulkStar},
+		{"Delete", bulkDelete},
+		{"Clear", bulkClear},
+	}
+	row := make([]tgbotapi.InlineKeyboardButton, 0, len(actions))
+	for _, action := range actions {
+		b, err := button(action.label, action.action)
+		if err != nil {
+			return tgbotapi.InlineKeyboardMarkup{}, err
+		}
+		row = append(row, b)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row), s.SetSetting(selectionTokensSetting, strings.Join(tokens, ","))
+}
+
+// deleteSelectionTokens removes the tokens behind the current bulk action buttons
+func deleteSelectionTokens(s store.Store) error {
+	setting, err := s.GetSetting(selectionTokensSetting)
+	if errors.Is(err, store.ErrNotFound) || setting == "" {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, token := range strings.Split(setting, ",") {
+		if err := s.DeleteCallbackToken(token); err != nil {
+			return err
+		}
+	}
+	return s.SetSetting(selectionTokensSetting, "")
+}
+
+// refreshSelectionMessage keeps the bulk action message in step with the selection,
+// sending it when the first entry is selected and removing it once none are
+func refreshSelectionMessage(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, s store.Store) error {
+	selection, err := s.GetSelection()
+	if err != nil {
+		return err
+	}
+
+	messageID := 0
+	if setting, err := s.GetSetting(selectionMessageSetting); err == nil {
+		messageID, _ = strconv.Atoi(setting)
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	if len(selection) == 0 {
+		if messageID != 0 {
+			bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, messageID))
+		}
+		if err := deleteSelectionTokens(s); err != nil {
+			return err
+		}
+		return s.SetSetting(selectionMessageSetting, "")
+	}
+
+	entryIDs := make([]int64, 0, len(selection))
+	for _, selected := range selection {
+		entryIDs = append(entryIDs, selected.EntryID)
+	}
+	keyboard, err := selectionKeyboard(s, chatID, secret, entryIDs)
+	if err != nil {
+		return err
+	}
+
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, selectionText(len(selection)))
+		edit.ReplyMarkup = &keyboard
+		if _, err := bot.Send(edit); err == nil {
+			return nil
+		}
+		// The message has probably been deleted, so send a new one
+		slog.Warn("Failed updating bulk action message, sending a new one", "error", err)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, selectionText(len(selection)))
+	msg.DisableNotification = true
+	msg.ReplyMarkup = keyboard
+	message, err := bot.Send(msg)
+	if err != nil {
+		return err
+	}
+	return s.SetSetting(selectionMessageSetting, strconv.Itoa(message.MessageID))
+}
+
+// selectedMessages maps selected entries to their Telegram messages
+func selectedMessages(s store.Store) (map[int64]int, error) {
+	selection, err := s.GetSelection()
+	if err != nil {
+		return nil, err
+	}
+	messages := make(map[int64]int, len(selection))
+	for _, selected := range selection {
+		messages[selected.EntryID] = selected.TelegramID
+	}
+	return messages, nil
+}
+
+// starEntries stars every entry which isn't already starred. Miniflux can only
+// toggle bookmarks one entry at a time, but the starred ones are fetched together.
+func starEntries(rss *miniflux.Client, entryIDs []int64) error {
+	if len(entryIDs) == 0 {
+		return nil
+	}
+	first, last := slices.Min(entryIDs), slices.Max(entryIDs)
+	starred, err := starredEntries(rss, first-1, last+1)
+	if err != nil {
+		return err
+	}
+	for _, entryID := range entriesToStar(entryIDs, starred) {
+		if err := rss.ToggleBookmark(entryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entriesToStar picks the entries which aren't in the starred list
+func entriesToStar(entryIDs []int64, starred miniflux.Entries) []int64 {
+	isStarred := make(map[int64]bool, len(starred))
+	for _, entry := range starred {
+		isStarred[entry.ID] = true
+	}
+	toStar := make([]int64, 0, len(entryIDs))
+	for _, entryID := range entryIDs {
+		if !isStarred[entryID] {
+			toStar = append(toStar, entryID)
+		}
+	}
+	return toStar
+}
+
+// deleteSelectedMessages removes the messages for a set of selected entries the same way as
+// the Delete button, returning the entries whose messages were removed. It carries on past
+// failures so one stuck message doesn't stop the rest, and returns the first error.
+func (a entryAction) deleteSelectedMessages(entryIDs []int64) ([]int64, error) {
+	messages, err := selectedMessages(a.store)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	deleted := make([]int64, 0, len(entryIDs))
+	for _, entryID := range entryIDs {
+		messageID, ok := messages[entryID]
+		if !ok {
+			continue
+		}
+		single := a
+		single.messageID, single.entryID = messageID, entryID
+		if err := single.removeMessage(false); err != nil {
+			slog.Error("Failed deleting selected message", "error", err, "entry", entryID)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		deleted = append(deleted, entryID)
+	}
+	return deleted, firstErr
+}
+
+// clearSelected removes entries from the selection, updating their keyboards
+// unless their messages have been deleted, and refreshes the bulk action message
+func clearSelected(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, s store.Store, entryIDs []int64, updateKeyboards bool) error {
+	messages, err := selectedMessages(s)
+	if err != nil {
+		return err
+	}
+	for _, entryID := range entryIDs {
+		if err := s.DeleteSelection(entryID); err != nil {
+			return err
+		}
+		if messageID, ok := messages[entryID]; ok && updateKeyboards {
+			go updateKeyboard(bot, chatID, secret, rss, s, messageID, entryID)
+		}
+	}
+	return refreshSelectionMessage(bot, chatID, secret, s)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/store/memory"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+func TestSelectionText(t *testing.T) {
+	var tests = []struct {
+		count    int
+		expected string
+	}{
+		{1, "1 entry selected"},
+		{2, "2 entries selected"},
+		{15, "15 entries selected"},
+	}
+
+	for _, tt := range tests {
+		if text := selectionText(tt.count); text != tt.expected {
+			t.Errorf("count [%d], got %q, want %q", tt.count, text, tt.expected)
+		}
+	}
+}
+
+func TestEntriesToStar(t *testing.T) {
+	var tests = []struct {
+		entryIDs    []int64
+		starred     miniflux.Entries
+		expected    []int64
+		explanation string
+	}{
+		{[]int64{1, 2, 3}, miniflux.Entries{}, []int64{1, 2, 3}, "Nothing is starred yet"},
+		{[]int64{1, 2, 3}, miniflux.Entries{{ID: 2}}, []int64{1, 3}, "Starred entries aren't toggled back off"},
+		{[]int64{1, 3}, miniflux.Entries{{ID: 1}, {ID: 2}, {ID: 3}}, []int64{}, "Other starred entries in the range are ignored"},
+	}
+
+	for _, tt := range tests {
+		if toStar := entriesToStar(tt.entryIDs, tt.starred); !reflect.DeepEqual(toStar, tt.expected) {
+			t.Errorf("%s: got %v, want %v", tt.explanation, toStar, tt.expected)
+		}
+	}
+}
+
+func TestSelectionKeyboardReplacesTokens(t *testing.T) {
+	s := memory.New()
+	secret := types.TelegramSecret("oohue2Oob3leyah")
+	const chatID = 12345
+
+	tokens := func() []string {
+		keyboard, err := selectionKeyboard(s, chatID, secret, []int64{1, 2})
+		if err != nil {
+			t.Fatalf("selectionKeyboard: %v", err)
+		}
+		tokens := make([]string, 0)
+		for _, button := range keyboard.InlineKeyboard[0] {
+			data, err := decodeCallback(secret, *button.CallbackData)
+			if err != nil || data.Action != tokenAction {
+				t.Fatalf("Button %q isn't a token button: %v", button.Text, err)
+			}
+			tokens = append(tokens, data.Param)
+		}
+		return tokens
+	}
+
+	first := tokens()
+	second := tokens()
+	for _, token := range first {
+		if _, err := s.GetCallbackToken(token); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Token %q from the replaced keyboard should be removed, got %v", token, err)
+		}
+	}
+	for _, token := range second {
+		payload, err := resolveCallbackToken(s, token, chatID, time.Now())
+		if err != nil {
+			t.Errorf("Token %q from the current keyboard should work, got %v", token, err)
+		}
+		if !reflect.DeepEqual(payload.EntryIDs, []int64{1, 2}) {
+			t.Errorf("Token %q: got entries %v, want [1 2]", token, payload.EntryIDs)
+		}
+	}
+
+	if err := deleteSelectionTokens(s); err != nil {
+		t.Fatalf("deleteSelectionTokens: %v", err)
+	}
+	for _, token := range second {
+		if _, err := s.GetCallbackToken(token); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Token %q should be removed once the selection is empty, got %v", token, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// replyShortcut is a word that can be replied to an entry message to act on it
+type replyShortcut struct {
+	Word        string
+	Action      string
+	Description string
+}
+
+var replyShortcuts = []replyShortcut{
+	{"read", markRead, "Reply to an entry to mark it as read"},
+	{"unread", markUnread, "Reply to an entry to mark it as unread"},
+	{"star", star, "Reply to an entry to star or unstar it"},
+	{"del", deleteMessage, "Reply to an entry to delete its message"},
+	{"later", later, "Reply to an entry to snooze it"},
+}
+
+// shortcutAction returns the action for a reply shortcut, with or without a leading slash
+func shortcutAction(text string) (string, bool) {
+	word := strings.ToLower(strings.TrimSpace(text))
+	word = strings.TrimPrefix(word, "/")
+	if at := strings.Index(word, "@"); at != -1 {
+		// Commands in groups can be addressed to us, e.g. /star@our_bot
+		word = word[:at]
+	}
+	for _, shortcut := range replyShortcuts {
+		if shortcut.Word == word {
+			return shortcut.Action, true
+		}
+	}
+	return "", false
+}
+
+// runShortcut applies an action to the entry of the message a shortcut replied to
+func runShortcut(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, s store.Store, message *tgbotapi.Message, action string) error {
+	if !isInEntryChat(message, chatID) {
+		return replyText(bot, message.Chat.ID, message.MessageID, "Shortcuts only work on entry messages in the chat the bot sends them to")
+	}
+	entry, err := s.GetEntryByTelegramID(message.ReplyToMessage.MessageID)
+	if errors.Is(err, store.ErrNotFound) {
+		return replyText(bot, chatID, message.MessageID, "That message isn't linked to an entry any more")
+	} else if err != nil {
+		return err
+	}
+
+	reply, err := entryAction{
+		bot:       bot,
+		chatID:    chatID,
+		secret:    secret,
+		rss:       rss,
+		store:     s,
+		messageID: entry.TelegramID,
+		entryID:   entry.ID,
+	}.run(action)
+	if err != nil {
+		if replyErr := replyText(bot, chatID, message.MessageID, reply); replyErr != nil {
+			slog.Error("Failed sending message for shortcut", "error", replyErr)
+		}
+		return err
+	}
+
+	// Remove the shortcut so the chat only contains entries
+	if _, err := bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, message.MessageID)); err != nil {
+		slog.Warn("Failed deleting shortcut message", "error", err)
+	}
+	return nil
+}
+
+// shortcutCommand handles a reply shortcut sent as a command, e.g. /star
+func shortcutCommand(shortcut replyShortcut) func(ctx commandContext) error {
+	return func(ctx commandContext) error {
+		if !isReplyToBot(ctx.bot, ctx.message) {
+			return ctx.reply("Reply to an entry message with /" + shortcut.Word + " to use it")
+		}
+		return runShortcut(ctx.bot, ctx.chatID, ctx.secret, ctx.rss, ctx.store, ctx.message, shortcut.Action)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestShortcutAction(t *testing.T) {
+	var tests = []struct {
+		input    string
+		action   string
+		expected bool
+	}{
+		{"read", markRead, true},
+		{" Read ", markRead, true},
+		{"/star", star, true},
+		{"/star@miniflux_bot", star, true},
+		{"del", deleteMessage, true},
+		{"later", later, true},
+		{"great article", "", false},
+	}
+
+	for _, tt := range tests {
+		action, ok := shortcutAction(tt.input)
+		if ok != tt.expected || action != tt.action {
+			t.Errorf("input [%s], got (%s, %v), want (%s, %v)", tt.input, action, ok, tt.action, tt.expected)
+		}
+	}
+}
+
+func TestIsInEntryChat(t *testing.T) {
+	const chatID = 1
+	var tests = []struct {
+		message     *tgbotapi.Message
+		expected    bool
+		explanation string
+	}{
+		{&tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}}, true, "Replies in the entry chat can refer to entries"},
+		{&tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 2}}, false, "Replies in other chats share message IDs with unrelated entries"},
+		{&tgbotapi.Message{}, false, "Messages without a chat can't refer to entries"},
+	}
+
+	for _, tt := range tests {
+		if got := isInEntryChat(tt.message, chatID); got != tt.expected {
+			t.Errorf("%s: got %v, want %v", tt.explanation, got, tt.expected)
+		}
+	}
+}
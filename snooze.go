@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// Options offered when snoozing an entry
+const (
+	snoozeHour     string = "1h"
+	snoozeHours    string = "3h"
+	snoozeTonight  string = "tonight"
+	snoozeTomorrow string = "tomorrow"
+)
+
+const (
+	tonightHour  = 20 // Tonight means 8pm
+	tomorrowHour = 8  // Tomorrow means 8am
+)
+
+// snoozeUntil works out when a snoozed entry should be sent again
+func snoozeUntil(option string, now time.Time) (time.Time, error) {
+	switch option {
+	case snoozeHour:
+		return now.Add(time.Hour), nil
+	case snoozeHours:
+		return now.Add(3 * time.Hour), nil
+	case snoozeTonight:
+		tonight := time.Date(now.Year(), now.Month(), now.Day(), tonightHour, 0, 0, 0, now.Location())
+		if !tonight.After(now) {
+			// It's already evening, remind them the next evening
+			tonight = tonight.AddDate(0, 0, 1)
+		}
+		return tonight, nil
+	case snoozeTomorrow:
+		tomorrow := time.Date(now.Year(), now.Month(), now.Day(), tomorrowHour, 0, 0, 0, now.Location())
+		return tomorrow.AddDate(0, 0, 1), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown snooze option %q", option)
+}
+
+// laterKeyboard lets the user pick when a snoozed entry should come back
+func laterKeyboard(secret types.TelegramSecret, entryID int64) tgbotapi.InlineKeyboardMarkup {
+	option := func(label string, option string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(label, callbackData(secret, snooze, entryID, option))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			option("1 hour", snoozeHour),
+			option("3 hours", snoozeHours),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			option("Tonight", snoozeTonight),
+			option("Tomorrow", snoozeTomorrow),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Cancel", callbackData(secret, cancelLater, entryID, "")),
+		),
+	)
+}
+
+// showLaterOptions swaps an entry's keyboard for the snooze options
+func showLaterOptions(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, messageID int, entryID int64) error {
+	_, err := bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, laterKeyboard(secret, entryID)))
+	return err
+}
+
+// snoozeEntry removes an entry's message and saves it to be sent again later
+func snoozeEntry(bot *tgbotapi.BotAPI, chatID int64, s store.Store, messageID int, entryID int64, until time.Time) error {
+	// Keep the message's DeleteRead setting so it comes back the way it was sent
+	deleteRead := true
+	msg, err := s.GetEntry(entryID)
+	if err == nil {
+		deleteRead = msg.DeleteRead
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	// Leave everything as it was if the message is still in the chat, otherwise it'd be sent twice
+	if _, err := bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil && !isMessageGone(err) {
+		return err
+	}
+	if err := s.InsertSnooze(models.Snooze{EntryID: entryID, Until: until, DeleteRead: deleteRead}); err != nil {
+		return err
+	}
+	// The message is gone, so drop its pin too and let it be pinned again once it's back
+	if err := s.DeletePin(entryID); err != nil {
+		return err
+	}
+	return s.DeleteEntryByID(entryID)
+}
+
+// sendSnoozedEntries periodically sends entries whose snooze has finished
+func sendSnoozedEntries(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, store store.Store) {
+	for {
+		snoozes, err := store.GetDueSnoozes(time.Now())
+		if err != nil {
+			slog.Error("Failed getting snoozed entries", "error", err)
+		}
+
+		for _, snoozed := range snoozes {
+			entry, err := rss.Entry(snoozed.EntryID)
+			if errors.Is(err, miniflux.ErrNotFound) {
+				// The entry is gone from Miniflux so there's nothing left to send
+				slog.Info("Dropping snooze for missing entry", "entry", snoozed.EntryID)
+				if err := store.DeleteSnooze(snoozed.EntryID); err != nil {
+					slog.Error("Failed deleting snooze in storage", "error", err)
+				}
+				continue
+			} else if err != nil {
+				slog.Error("Failed getting Miniflux entry", "error", err, "entry", snoozed.EntryID)
+				continue
+			}
+			if err := sendMsg(bot, chatID, secret, entry, viper.GetBool("TELEGRAM_SILENT_NOTIFICATION"), snoozed.DeleteRead, store); err != nil {
+				slog.Error("Failed sending message for snoozed entry", "error", err, "entry", entry.ID)
+				continue
+			}
+			if err := store.DeleteSnooze(snoozed.EntryID); err != nil {
+				slog.Error("Failed deleting snooze in storage", "error", err)
+			}
+			slog.Info("Message sent for snoozed entry", "entry", entry.ID)
+		}
+
+		time.Sleep(time.Minute)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnoozeUntil(t *testing.T) {
+	morning := time.Date(2024, 3, 10, 9, 30, 0, 0, time.UTC)
+	night := time.Date(2024, 3, 10, 22, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		explanation   string
+		option        string
+		now           time.Time
+		expected      time.Time
+		validExpected bool
+	}{
+		{
+			"One hour from now",
+			snoozeHour,
+			morning,
+			morning.Add(time.Hour),
+			true,
+		}, {
+			"Three hours from now",
+			snoozeHours,
+			morning,
+			morning.Add(3 * time.Hour),
+			true,
+		}, {
+			"Tonight in the morning is this evening",
+			snoozeTonight,
+			morning,
+			time.Date(2024, 3, 10, 20, 0, 0, 0, time.UTC),
+			true,
+		}, {
+			"Tonight late at night is the next evening",
+			snoozeTonight,
+			night,
+			time.Date(2024, 3, 11, 20, 0, 0, 0, time.UTC),
+			true,
+		}, {
+			"Tomorrow is the next morning",
+			snoozeTomorrow,
+			night,
+			time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC),
+			true,
+		}, {
+			"Unknown option is invalid",
+			"never",
+			morning,
+			time.Time{},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		until, err := snoozeUntil(tt.option, tt.now)
+		if (err == nil) != tt.validExpected {
+			t.Errorf("%s: input [%s], got %v, want %v", tt.explanation, tt.option, err, tt.validExpected)
+		}
+		if !until.Equal(tt.expected) {
+			t.Errorf("%s: input [%s], got %v, want %v", tt.explanation, tt.option, until, tt.expected)
+		}
+	}
+}
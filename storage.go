@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/store/postgres"
+	"go.jloh.dev/miniflux-telegram-bot/store/sqlite"
+)
+
+// Storage drivers selected with STORE_DRIVER
+const (
+	storeSQLite   = "sqlite"
+	storePostgres = "postgres"
+)
+
+// validateStoreConfig checks the storage settings so mistakes are caught on startup
+func validateStoreConfig() error {
+	switch driver := viper.GetString("STORE_DRIVER"); driver {
+	case storeSQLite:
+		return nil
+	case storePostgres:
+		if viper.GetString("STORE_DSN") == "" {
+			return fmt.Errorf("STORE_DSN must be set when STORE_DRIVER is postgres")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown STORE_DRIVER %q, expected sqlite or postgres", driver)
+	}
+}
+
+// openStore connects to the configured storage driver and runs its migrations
+func openStore() store.Store {
+	switch viper.GetString("STORE_DRIVER") {
+	case storePostgres:
+		postgres.EmbedMigrations = embedMigrations
+		return postgres.New(viper.GetString("STORE_DSN"))
+	default:
+		sqlite.EmbedMigrations = embedMigrations
+		return sqlite.New()
+	}
+}
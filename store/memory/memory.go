@@ -0,0 +1,411 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+)
+
+// db keeps everything in maps, so state is lost when the bot restarts
+type db struct {
+	mu             sync.Mutex
+	entries        map[int64]models.Message
+	settings       map[string]string
+	telegraphPages map[int64]models.TelegraphPage
+	snoozes        map[int64]models.Snooze
+	feedMutes      map[int64]models.FeedMute
+	pendingDeletes map[int]models.PendingDelete
+	selection      map[int64]models.Selection
+	pins           map[int64]models.Pin
+	notes          []models.Note
+	lastNoteID     int64
+	callbackTokens map[string]models.CallbackToken
+}
+
+// seconds truncates a time to what SQLite keeps, so every store compares times the same way
+func seconds(t time.Time) time.Time {
+	return t.Truncate(store.TimePrecision)
+}
+
+func New() store.Store {
+	return &db{
+		entries:        make(map[int64]models.Message),
+		settings:       make(map[string]string),
+		telegraphPages: make(map[int64]models.TelegraphPage),
+		snoozes:        make(map[int64]models.Snooze),
+		feedMutes:      make(map[int64]models.FeedMute),
+		pendingDeletes: make(map[int]models.PendingDelete),
+		selection:      make(map[int64]models.Selection),
+		pins:           make(map[int64]models.Pin),
+		notes:          make([]models.Note, 0),
+		callbackTokens: make(map[string]models.CallbackToken),
+	}
+}
+
+func (d *db) GetEntry(id int64) (models.Message, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	msg, ok := d.entries[id]
+	if !ok {
+		return msg, store.ErrNotFound
+	}
+	return msg, nil
+}
+
+func (d *db) InsertEntry(msg models.Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.entries[msg.ID]; ok {
+		return fmt.Errorf("entry %d already exists", msg.ID)
+	}
+	msg.SentTime = seconds(msg.SentTime)
+	msg.UpdatedTime = seconds(msg.UpdatedTime)
+	d.entries[msg.ID] = msg
+	return nil
+}
+
+func (d *db) UpdateEntryTime(id int64, updated time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if msg, ok := d.entries[id]; ok {
+		msg.UpdatedTime = seconds(updated)
+		d.entries[id] = msg
+	}
+	return nil
+}
+
+func (d *db) UpdateEntryFingerprint(id int64, fingerprint string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if msg, ok := d.entries[id]; ok {
+		msg.Fingerprint = fingerprint
+		d.entries[id] = msg
+	}
+	return nil
+}
+
+func (d *db) GetEntries() ([]models.Message, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	results := make([]models.Message, 0, len(d.entries))
+	for _, msg := range d.entries {
+		results = append(results, msg)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	return results, nil
+}
+
+func (d *db) DeleteEntryByID(id int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.entries, id)
+	return nil
+}
+
+func (d *db) DeleteEntryByTelegramID(id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for entryID, msg := range d.entries {
+		if msg.TelegramID == id {
+			delete(d.entries, entryID)
+		}
+	}
+	return nil
+}
+
+func (d *db) GetEntryByTelegramID(id int) (models.Message, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, msg := range d.entries {
+		if msg.TelegramID == id {
+			return msg, nil
+		}
+	}
+	return models.Message{}, store.ErrNotFound
+}
+
+func (d *db) GetSetting(key string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	value, ok := d.settings[key]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	return value, nil
+}
+
+func (d *db) SetSetting(key string, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.settings[key] = value
+	return nil
+}
+
+func (d *db) GetTelegraphPage(entryID int64) (models.TelegraphPage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	page, ok := d.telegraphPages[entryID]
+	if !ok {
+		return page, store.ErrNotFound
+	}
+	return page, nil
+}
+
+func (d *db) InsertTelegraphPage(page models.TelegraphPage) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	page.Created = seconds(page.Created)
+	d.telegraphPages[page.EntryID] = page
+	return nil
+}
+
+func (d *db) InsertSnooze(snooze models.Snooze) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	snooze.Until = seconds(snooze.Until)
+	d.snoozes[snooze.EntryID] = snooze
+	return nil
+}
+
+func (d *db) GetDueSnoozes(before time.Time) ([]models.Snooze, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	results := make([]models.Snooze, 0)
+	for _, snooze := range d.snoozes {
+		if !snooze.Until.After(seconds(before)) {
+			results = append(results, snooze)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Until.Before(results[j].Until) })
+	return results, nil
+}
+
+func (d *db) DeleteSnooze(entryID int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.snoozes, entryID)
+	return nil
+}
+
+func (d *db) InsertFeedMute(mute models.FeedMute) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	mute.Until = seconds(mute.Until)
+	d.feedMutes[mute.FeedID] = mute
+	return nil
+}
+
+func (d *db) GetFeedMutes(at time.Time) ([]models.FeedMute, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	results := make([]models.FeedMute, 0)
+	for _, mute := range d.feedMutes {
+		if mute.Until.IsZero() || mute.Until.After(seconds(at)) {
+			results = append(results, mute)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].FeedTitle < results[j].FeedTitle })
+	return results, nil
+}
+
+func (d *db) DeleteFeedMute(feedID int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.feedMutes, feedID)
+	return nil
+}
+
+func (d *db) DeleteExpiredFeedMutes(before time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var removed int64
+	for feedID, mute := range d.feedMutes {
+		if !mute.Until.IsZero() && !mute.Until.After(seconds(before)) {
+			delete(d.feedMutes, feedID)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (d *db) InsertPendingDelete(pending models.PendingDelete) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pending.Entry.SentTime = seconds(pending.Entry.SentTime)
+	pending.Entry.UpdatedTime = seconds(pending.Entry.UpdatedTime)
+	pending.Expires = seconds(pending.Expires)
+	d.pendingDeletes[pending.Entry.TelegramID] = pending
+	return nil
+}
+
+func (d *db) GetPendingDelete(telegramID int) (models.PendingDelete, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pending, ok := d.pendingDeletes[telegramID]
+	if !ok {
+		return pending, store.ErrNotFound
+	}
+	return pending, nil
+}
+
+func (d *db) GetExpiredPendingDeletes(before time.Time) ([]models.PendingDelete, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	results := make([]models.PendingDelete, 0)
+	for _, pending := range d.pendingDeletes {
+		if !pending.Expires.After(seconds(before)) {
+			results = append(results, pending)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Expires.Before(results[j].Expires) })
+	return results, nil
+}
+
+func (d *db) DeletePendingDelete(telegramID int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pendingDeletes, telegramID)
+	return nil
+}
+
+func (d *db) InsertSelection(selection models.Selection) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	selection.Added = seconds(selection.Added)
+	d.selection[selection.EntryID] = selection
+	return nil
+}
+
+func (d *db) GetSelection() ([]models.Selection, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	results := make([]models.Selection, 0, len(d.selection))
+	for _, selection := range d.selection {
+		results = append(results, selection)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Added.Equal(results[j].Added) {
+			return results[i].Added.Before(results[j].Added)
+		}
+		return results[i].EntryID < results[j].EntryID
+	})
+	return results, nil
+}
+
+func (d *db) DeleteSelection(entryID int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.selection, entryID)
+	return nil
+}
+
+func (d *db) InsertPin(pin models.Pin) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pin.Pinned = seconds(pin.Pinned)
+	d.pins[pin.EntryID] = pin
+	return nil
+}
+
+func (d *db) GetPins() ([]models.Pin, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	results := make([]models.Pin, 0, len(d.pins))
+	for _, pin := range d.pins {
+		results = append(results, pin)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Pinned.Equal(results[j].Pinned) {
+			return results[i].Pinned.Before(results[j].Pinned)
+		}
+		return results[i].EntryID < results[j].EntryID
+	})
+	return results, nil
+}
+
+func (d *db) DeletePin(entryID int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pins, entryID)
+	return nil
+}
+
+func (d *db) InsertNote(note models.Note) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastNoteID++
+	note.ID = d.lastNoteID
+	note.Created = seconds(note.Created)
+	d.notes = append(d.notes, note)
+	return nil
+}
+
+// Notes are kept in the order they were added, so they're already sorted by ID
+func (d *db) GetNotes() ([]models.Note, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	results := make([]models.Note, len(d.notes))
+	copy(results, d.notes)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].EntryID < results[j].EntryID })
+	return results, nil
+}
+
+func (d *db) GetNotesByEntryID(entryID int64) ([]models.Note, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	results := make([]models.Note, 0)
+	for _, note := range d.notes {
+		if note.EntryID == entryID {
+			results = append(results, note)
+		}
+	}
+	return results, nil
+}
+
+func (d *db) InsertCallbackToken(token models.CallbackToken) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.callbackTokens[token.Token]; ok {
+		return fmt.Errorf("callback token %q already exists", token.Token)
+	}
+	// Copy the IDs so later changes by the caller don't leak into the store
+	token.EntryIDs = append(make([]int64, 0, len(token.EntryIDs)), token.EntryIDs...)
+	token.Expires = seconds(token.Expires)
+	d.callbackTokens[token.Token] = token
+	return nil
+}
+
+func (d *db) GetCallbackToken(token string) (models.CallbackToken, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result, ok := d.callbackTokens[token]
+	if !ok {
+		return result, store.ErrNotFound
+	}
+	result.EntryIDs = append(make([]int64, 0, len(result.EntryIDs)), result.EntryIDs...)
+	return result, nil
+}
+
+func (d *db) DeleteCallbackToken(token string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.callbackTokens, token)
+	return nil
+}
+
+func (d *db) DeleteExpiredCallbackTokens(before time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var removed int64
+	for token, result := range d.callbackTokens {
+		if result.Expires.Before(seconds(before)) {
+			delete(d.callbackTokens, token)
+			removed++
+		}
+	}
+	return removed, nil
+}
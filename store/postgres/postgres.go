@@ -0,0 +1,491 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/pressly/goose/v3"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+)
+
+var EmbedMigrations fs.FS
+
+type db struct {
+	ctx *sql.DB
+}
+
+func New(dsn string) store.Store {
+	s, err := open(dsn)
+	if err != nil {
+		slog.Error("[store] failed opening DB", "error", err)
+		os.Exit(1)
+	}
+	return s
+}
+
+// seconds drops anything finer than TimePrecision, since TIMESTAMPTZ would keep microseconds
+func seconds(t time.Time) time.Time {
+	return t.Truncate(store.TimePrecision)
+}
+
+// open connects to the database at dsn and runs any pending migrations
+func open(dsn string) (*db, error) {
+	ctx, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Ping(); err != nil {
+		return nil, err
+	}
+	// Run migrations
+	if err := goose.SetDialect("postgres"); err != nil {
+		return nil, fmt.Errorf("setting Goose to postgres: %w", err)
+	}
+	goose.SetBaseFS(EmbedMigrations)
+
+	if err := goose.Up(ctx, "migrations/postgres"); err != nil {
+		return nil, fmt.Errorf("running migrations: %w", err)
+	}
+	return &db{
+		ctx: ctx,
+	}, nil
+}
+
+func (d db) GetEntry(id int64) (models.Message, error) {
+	var msg models.Message
+	err := d.ctx.QueryRow("SELECT id, telegram_id, sent_time, updated, delete_read, fingerprint FROM entries where id=$1", id).Scan(
+		&msg.ID, &msg.TelegramID, &msg.SentTime, &msg.UpdatedTime, &msg.DeleteRead, &msg.Fingerprint,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return msg, store.ErrNotFound
+	}
+	return msg, err
+}
+
+func (d db) InsertEntry(msg models.Message) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO entries(
+		id,
+		telegram_id,
+		sent_time,
+		updated,
+		delete_read,
+		fingerprint
+	)
+	VALUES($1,$2,$3,$4,$5,$6)`, msg.ID, msg.TelegramID, seconds(msg.SentTime), seconds(msg.UpdatedTime), msg.DeleteRead, msg.Fingerprint)
+	return err
+}
+
+func (d db) UpdateEntryTime(id int64, updated time.Time) error {
+	_, err := d.ctx.Exec("UPDATE entries set updated=$1 where id=$2", seconds(updated), id)
+	return err
+}
+
+func (d db) UpdateEntryFingerprint(id int64, fingerprint string) error {
+	_, err := d.ctx.Exec("UPDATE entries set fingerprint=$1 where id=$2", fingerprint, id)
+	return err
+}
+
+func (d db) GetEntries() ([]models.Message, error) {
+	results := make([]models.Message, 0)
+	res, err := d.ctx.Query("SELECT id, telegram_id, sent_time, updated, delete_read, fingerprint FROM entries")
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var msg models.Message
+		if err := res.Scan(&msg.ID, &msg.TelegramID, &msg.SentTime, &msg.UpdatedTime, &msg.DeleteRead, &msg.Fingerprint); err != nil {
+			continue
+		}
+		results = append(results, msg)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) DeleteEntryByID(id int64) error {
+	_, err := d.ctx.Exec("DELETE from entries where id=$1", id)
+	return err
+}
+
+func (d db) DeleteEntryByTelegramID(id int) error {
+	_, err := d.ctx.Exec("DELETE from entries where telegram_id=$1", id)
+	return err
+}
+
+func (d db) GetEntryByTelegramID(id int) (models.Message, error) {
+	var msg models.Message
+	err := d.ctx.QueryRow("SELECT id, telegram_id, sent_time, updated, delete_read, fingerprint FROM entries where telegram_id=$1", id).Scan(
+		&msg.ID, &msg.TelegramID, &msg.SentTime, &msg.UpdatedTime, &msg.DeleteRead, &msg.Fingerprint,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return msg, store.ErrNotFound
+	}
+	return msg, err
+}
+
+func (d db) GetSetting(key string) (string, error) {
+	var value string
+	err := d.ctx.QueryRow("SELECT value FROM settings where key=$1", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", store.ErrNotFound
+	}
+	return value, err
+}
+
+func (d db) SetSetting(key string, value string) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO settings(key, value) VALUES($1,$2)
+	ON CONFLICT(key) DO UPDATE SET value=excluded.value`, key, value)
+	return err
+}
+
+func (d db) GetTelegraphPage(entryID int64) (models.TelegraphPage, error) {
+	var page models.TelegraphPage
+	err := d.ctx.QueryRow("SELECT entry_id, path, url, created FROM telegraph_pages where entry_id=$1", entryID).Scan(&page.EntryID, &page.Path, &page.URL, &page.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return page, store.ErrNotFound
+	}
+	return page, err
+}
+
+func (d db) InsertTelegraphPage(page models.TelegraphPage) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO telegraph_pages(
+		entry_id,
+		path,
+		url,
+		created
+	)
+	VALUES($1,$2,$3,$4)
+	ON CONFLICT(entry_id) DO UPDATE SET path=excluded.path, url=excluded.url, created=excluded.created`,
+		page.EntryID, page.Path, page.URL, seconds(page.Created))
+	return err
+}
+
+func (d db) InsertSnooze(snooze models.Snooze) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO snoozes(
+		entry_id,
+		until,
+		delete_read
+	)
+	VALUES($1,$2,$3)
+	ON CONFLICT(entry_id) DO UPDATE SET until=excluded.until, delete_read=excluded.delete_read`,
+		snooze.EntryID, seconds(snooze.Until), snooze.DeleteRead)
+	return err
+}
+
+func (d db) GetDueSnoozes(before time.Time) ([]models.Snooze, error) {
+	results := make([]models.Snooze, 0)
+	res, err := d.ctx.Query("SELECT entry_id, until, delete_read FROM snoozes where until<=$1 ORDER BY until", seconds(before))
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var snooze models.Snooze
+		if err := res.Scan(&snooze.EntryID, &snooze.Until, &snooze.DeleteRead); err != nil {
+			continue
+		}
+		results = append(results, snooze)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) DeleteSnooze(entryID int64) error {
+	_, err := d.ctx.Exec("DELETE from snoozes where entry_id=$1", entryID)
+	return err
+}
+
+// Mutes without an end time have a NULL until
+func (d db) InsertFeedMute(mute models.FeedMute) error {
+	var until sql.NullTime
+	if !mute.Until.IsZero() {
+		until = sql.NullTime{Time: seconds(mute.Until), Valid: true}
+	}
+	_, err := d.ctx.Exec(`
+	INSERT INTO feed_mutes(
+		feed_id,
+		feed_title,
+		until
+	)
+	VALUES($1,$2,$3)
+	ON CONFLICT(feed_id) DO UPDATE SET feed_title=excluded.feed_title, until=excluded.until`,
+		mute.FeedID, mute.FeedTitle, until)
+	return err
+}
+
+func (d db) GetFeedMutes(at time.Time) ([]models.FeedMute, error) {
+	results := make([]models.FeedMute, 0)
+	res, err := d.ctx.Query("SELECT feed_id, feed_title, until FROM feed_mutes where until IS NULL OR until>$1 ORDER BY feed_title", seconds(at))
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var mute models.FeedMute
+		var until sql.NullTime
+		if err := res.Scan(&mute.FeedID, &mute.FeedTitle, &until); err != nil {
+			continue
+		}
+		if until.Valid {
+			mute.Until = until.Time
+		}
+		results = append(results, mute)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) DeleteFeedMute(feedID int64) error {
+	_, err := d.ctx.Exec("DELETE from feed_mutes where feed_id=$1", feedID)
+	return err
+}
+
+func (d db) DeleteExpiredFeedMutes(before time.Time) (int64, error) {
+	res, err := d.ctx.Exec("DELETE from feed_mutes where until IS NOT NULL AND until<=$1", seconds(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (d db) InsertPendingDelete(pending models.PendingDelete) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO pending_deletes(
+		telegram_id,
+		entry_id,
+		sent_time,
+		updated,
+		delete_read,
+		marked_read,
+		expires
+	)
+	VALUES($1,$2,$3,$4,$5,$6,$7)
+	ON CONFLICT(telegram_id) DO UPDATE SET
+		entry_id=excluded.entry_id,
+		sent_time=excluded.sent_time,
+		updated=excluded.updated,
+		delete_read=excluded.delete_read,
+		marked_read=excluded.marked_read,
+		expires=excluded.expires`,
+		pending.Entry.TelegramID,
+		pending.Entry.ID,
+		seconds(pending.Entry.SentTime),
+		seconds(pending.Entry.UpdatedTime),
+		pending.Entry.DeleteRead,
+		pending.MarkedRead,
+		seconds(pending.Expires),
+	)
+	return err
+}
+
+func (d db) GetPendingDelete(telegramID int) (models.PendingDelete, error) {
+	res, err := d.queryPendingDeletes("SELECT telegram_id, entry_id, sent_time, updated, delete_read, marked_read, expires FROM pending_deletes where telegram_id=$1", telegramID)
+	if err != nil {
+		return models.PendingDelete{}, err
+	}
+	if len(res) == 0 {
+		return models.PendingDelete{}, store.ErrNotFound
+	}
+	return res[0], nil
+}
+
+func (d db) GetExpiredPendingDeletes(before time.Time) ([]models.PendingDelete, error) {
+	return d.queryPendingDeletes("SELECT telegram_id, entry_id, sent_time, updated, delete_read, marked_read, expires FROM pending_deletes where expires<=$1 ORDER BY expires", seconds(before))
+}
+
+func (d db) DeletePendingDelete(telegramID int) error {
+	_, err := d.ctx.Exec("DELETE from pending_deletes where telegram_id=$1", telegramID)
+	return err
+}
+
+// queryPendingDeletes runs a query returning pending_deletes rows
+func (d db) queryPendingDeletes(query string, args ...any) ([]models.PendingDelete, error) {
+	results := make([]models.PendingDelete, 0)
+	res, err := d.ctx.Query(query, args...)
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var pending models.PendingDelete
+		if err := res.Scan(&pending.Entry.TelegramID, &pending.Entry.ID, &pending.Entry.SentTime, &pending.Entry.UpdatedTime, &pending.Entry.DeleteRead, &pending.MarkedRead, &pending.Expires); err != nil {
+			continue
+		}
+		results = append(results, pending)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) InsertSelection(selection models.Selection) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO selected_entries(
+		entry_id,
+		telegram_id,
+		added
+	)
+	VALUES($1,$2,$3)
+	ON CONFLICT(entry_id) DO UPDATE SET telegram_id=excluded.telegram_id, added=excluded.added`,
+		selection.EntryID, selection.TelegramID, seconds(selection.Added))
+	return err
+}
+
+func (d db) GetSelection() ([]models.Selection, error) {
+	results := make([]models.Selection, 0)
+	res, err := d.ctx.Query("SELECT entry_id, telegram_id, added FROM selected_entries ORDER BY added, entry_id")
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var selection models.Selection
+		if err := res.Scan(&selection.EntryID, &selection.TelegramID, &selection.Added); err != nil {
+			continue
+		}
+		results = append(results, selection)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) DeleteSelection(entryID int64) error {
+	_, err := d.ctx.Exec("DELETE from selected_entries where entry_id=$1", entryID)
+	return err
+}
+
+func (d db) InsertPin(pin models.Pin) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO pins(
+		entry_id,
+		telegram_id,
+		pinned
+	)
+	VALUES($1,$2,$3)
+	ON CONFLICT(entry_id) DO UPDATE SET telegram_id=excluded.telegram_id, pinned=excluded.pinned`,
+		pin.EntryID, pin.TelegramID, seconds(pin.Pinned))
+	return err
+}
+
+func (d db) GetPins() ([]models.Pin, error) {
+	results := make([]models.Pin, 0)
+	res, err := d.ctx.Query("SELECT entry_id, telegram_id, pinned FROM pins ORDER BY pinned, entry_id")
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var pin models.Pin
+		if err := res.Scan(&pin.EntryID, &pin.TelegramID, &pin.Pinned); err != nil {
+			continue
+		}
+		results = append(results, pin)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) DeletePin(entryID int64) error {
+	_, err := d.ctx.Exec("DELETE from pins where entry_id=$1", entryID)
+	return err
+}
+
+func (d db) InsertNote(note models.Note) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO notes(
+		entry_id,
+		text,
+		created
+	)
+	VALUES($1,$2,$3)`, note.EntryID, note.Text, seconds(note.Created))
+	return err
+}
+
+func (d db) GetNotes() ([]models.Note, error) {
+	return d.queryNotes("SELECT id, entry_id, text, created FROM notes ORDER BY entry_id, id")
+}
+
+func (d db) GetNotesByEntryID(entryID int64) ([]models.Note, error) {
+	return d.queryNotes("SELECT id, entry_id, text, created FROM notes where entry_id=$1 ORDER BY id", entryID)
+}
+
+func (d db) queryNotes(query string, args ...any) ([]models.Note, error) {
+	results := make([]models.Note, 0)
+	res, err := d.ctx.Query(query, args...)
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var note models.Note
+		if err := res.Scan(&note.ID, &note.EntryID, &note.Text, &note.Created); err != nil {
+			continue
+		}
+		results = append(results, note)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) InsertCallbackToken(token models.CallbackToken) error {
+	entryIDs := token.EntryIDs
+	if entryIDs == nil {
+		entryIDs = make([]int64, 0)
+	}
+	_, err := d.ctx.Exec(`
+	INSERT INTO callback_tokens(
+		token,
+		action,
+		entry_ids,
+		page,
+		chat_id,
+		param,
+		single_use,
+		expires
+	)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8)`, token.Token, token.Action, pq.Array(entryIDs), token.Page, token.ChatID, token.Param, token.SingleUse, seconds(token.Expires))
+	return err
+}
+
+func (d db) GetCallbackToken(token string) (models.CallbackToken, error) {
+	var result models.CallbackToken
+	err := d.ctx.QueryRow("SELECT token, action, entry_ids, page, chat_id, param, single_use, expires FROM callback_tokens where token=$1", token).Scan(
+		&result.Token, &result.Action, pq.Array(&result.EntryIDs), &result.Page, &result.ChatID, &result.Param, &result.SingleUse, &result.Expires,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result, store.ErrNotFound
+	}
+	return result, err
+}
+
+func (d db) DeleteCallbackToken(token string) error {
+	_, err := d.ctx.Exec("DELETE from callback_tokens where token=$1", token)
+	return err
+}
+
+func (d db) DeleteExpiredCallbackTokens(before time.Time) (int64, error) {
+	res, err := d.ctx.Exec("DELETE from callback_tokens where expires<$1", seconds(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"os"
+	"testing"
+
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/store/storetest"
+)
+
+// Set STORE_TEST_POSTGRES_DSN to a scratch database to run these, its tables are emptied by every test
+func TestStore(t *testing.T) {
+	dsn := os.Getenv("STORE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("STORE_TEST_POSTGRES_DSN isn't set")
+	}
+	// Migrations live at the root of the repo, where main embeds them
+	EmbedMigrations = os.DirFS("../..")
+
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := open(dsn)
+		if err != nil {
+			t.Fatalf("Opening store: %v", err)
+		}
+		t.Cleanup(func() { s.ctx.Close() })
+		_, err = s.ctx.Exec(`TRUNCATE entries, settings, telegraph_pages, snoozes, notes, callback_tokens,
+			feed_mutes, pending_deletes, selected_entries, pins RESTART IDENTITY`)
+		if err != nil {
+			t.Fatalf("Emptying store: %v", err)
+		}
+		return s
+	})
+}
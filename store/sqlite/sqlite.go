@@ -2,9 +2,13 @@ package sqlite
 
 import (
 	"database/sql"
-	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -14,7 +18,7 @@ import (
 	"go.jloh.dev/miniflux-telegram-bot/store"
 )
 
-var EmbedMigrations embed.FS
+var EmbedMigrations fs.FS
 
 type db struct {
 	ctx *sql.DB
@@ -27,37 +31,46 @@ func New() store.Store {
 			slog.Error("[store] failed creating storage directory", "error", err)
 		}
 	}
-	ctx, err := sql.Open("sqlite", dbDir+"/store.db")
+	s, err := open(dbDir + "/store.db")
 	if err != nil {
 		slog.Error("[store] failed opening DB", "error", err)
 		os.Exit(1)
 	}
+	return s
+}
+
+// open opens the database at path and runs any pending migrations
+func open(path string) (*db, error) {
+	ctx, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// The sync loop, snooze sender and update listener all write from their own goroutines.
+	// SQLite only allows one writer at a time and fails the others with SQLITE_BUSY rather
+	// than waiting, so share a single connection and let database/sql queue them instead.
+	ctx.SetMaxOpenConns(1)
+
 	// Run migrations
 	if err := goose.SetDialect("sqlite3"); err != nil {
-		slog.Error("[store] failed setting Goose to sqlite", "error", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("setting Goose to sqlite: %w", err)
 	}
 	goose.SetBaseFS(EmbedMigrations)
 
 	if err := goose.Up(ctx, "migrations"); err != nil {
-		slog.Error("[store] failed running migrations", "error", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("running migrations: %w", err)
 	}
 	return &db{
 		ctx: ctx,
-	}
+	}, nil
 }
 
-func (d db) GetEntry(id int) (models.Message, error) {
+func (d db) GetEntry(id int64) (models.Message, error) {
 	var msg models.Message
-	stmt, err := d.ctx.Prepare("SELECT id, telegram_id, sent_time, updated, delete_read FROM entries where id=?")
-	if err != nil {
-		return msg, err
-	}
-	defer stmt.Close()
-
 	var sent_time, updated_time string
-	err = stmt.QueryRow(id).Scan(&msg.ID, &msg.TelegramID, &sent_time, &updated_time, msg.DeleteRead)
+	err := d.ctx.QueryRow("SELECT id, telegram_id, sent_time, updated, delete_read, fingerprint FROM entries where id=?", id).Scan(&msg.ID, &msg.TelegramID, &sent_time, &updated_time, &msg.DeleteRead, &msg.Fingerprint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return msg, store.ErrNotFound
+	}
 	if err != nil {
 		return msg, err
 	}
@@ -82,9 +95,10 @@ func (d db) InsertEntry(msg models.Message) error {
 		telegram_id,
 		sent_time,
 		updated,
-		delete_read
+		delete_read,
+		fingerprint
 	)
-	VALUES(?,?,?,?,?)`, msg.ID, msg.TelegramID, msg.SentTime.Format(time.RFC3339), msg.UpdatedTime.Format(time.RFC3339), msg.DeleteRead)
+	VALUES(?,?,?,?,?,?)`, msg.ID, msg.TelegramID, msg.SentTime.Format(time.RFC3339), msg.UpdatedTime.Format(time.RFC3339), msg.DeleteRead, msg.Fingerprint)
 	return err
 }
 
@@ -104,9 +118,14 @@ func (d db) UpdateEntryTime(id int64, updated time.Time) error {
 	return nil
 }
 
+func (d db) UpdateEntryFingerprint(id int64, fingerprint string) error {
+	_, err := d.ctx.Exec("UPDATE entries set fingerprint=? where id=?", fingerprint, id)
+	return err
+}
+
 func (d db) GetEntries() ([]models.Message, error) {
 	results := make([]models.Message, 0)
-	stmt, err := d.ctx.Prepare("SELECT id, telegram_id, sent_time, updated, delete_read FROM entries")
+	stmt, err := d.ctx.Prepare("SELECT id, telegram_id, sent_time, updated, delete_read, fingerprint FROM entries")
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +140,7 @@ func (d db) GetEntries() ([]models.Message, error) {
 	for res.Next() {
 		var msg models.Message
 		var sent_time, updated_time string
-		if err := res.Scan(&msg.ID, &msg.TelegramID, &sent_time, &updated_time, &msg.DeleteRead); err != nil {
+		if err := res.Scan(&msg.ID, &msg.TelegramID, &sent_time, &updated_time, &msg.DeleteRead, &msg.Fingerprint); err != nil {
 			continue
 		}
 		// Parse sent_time
@@ -152,3 +171,455 @@ func (d db) DeleteEntryByTelegramID(id int) error {
 	`, id)
 	return err
 }
+
+func (d db) GetSetting(key string) (string, error) {
+	var value string
+	err := d.ctx.QueryRow("SELECT value FROM settings where key=?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", store.ErrNotFound
+	}
+	return value, err
+}
+
+func (d db) SetSetting(key string, value string) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO settings(key, value) VALUES(?,?)
+	ON CONFLICT(key) DO UPDATE SET value=excluded.value`, key, value)
+	return err
+}
+
+func (d db) GetTelegraphPage(entryID int64) (models.TelegraphPage, error) {
+	var page models.TelegraphPage
+	var created string
+	err := d.ctx.QueryRow("SELECT entry_id, path, url, created FROM telegraph_pages where entry_id=?", entryID).Scan(&page.EntryID, &page.Path, &page.URL, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return page, store.ErrNotFound
+	}
+	if err != nil {
+		return page, err
+	}
+
+	page.Created, err = time.Parse(time.RFC3339, created)
+	return page, err
+}
+
+func (d db) InsertTelegraphPage(page models.TelegraphPage) error {
+	_, err := d.ctx.Exec(`
+	INSERT OR REPLACE INTO telegraph_pages(
+		entry_id,
+		path,
+		url,
+		created
+	)
+	VALUES(?,?,?,?)`, page.EntryID, page.Path, page.URL, page.Created.Format(time.RFC3339))
+	return err
+}
+
+// Snooze times are stored in UTC so they can be compared as strings
+func (d db) InsertSnooze(snooze models.Snooze) error {
+	_, err := d.ctx.Exec(`
+	INSERT OR REPLACE INTO snoozes(
+		entry_id,
+		until,
+		delete_read
+	)
+	VALUES(?,?,?)`, snooze.EntryID, snooze.Until.UTC().Format(time.RFC3339), snooze.DeleteRead)
+	return err
+}
+
+func (d db) GetDueSnoozes(before time.Time) ([]models.Snooze, error) {
+	results := make([]models.Snooze, 0)
+	res, err := d.ctx.Query("SELECT entry_id, until, delete_read FROM snoozes where until<=? ORDER BY until", before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var snooze models.Snooze
+		var until string
+		if err := res.Scan(&snooze.EntryID, &until, &snooze.DeleteRead); err != nil {
+			continue
+		}
+		snooze.Until, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			continue
+		}
+		results = append(results, snooze)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) DeleteSnooze(entryID int64) error {
+	_, err := d.ctx.Exec(`
+	DELETE from snoozes where entry_id=?
+	`, entryID)
+	return err
+}
+
+// Mutes without an end time have a NULL until
+func (d db) InsertFeedMute(mute models.FeedMute) error {
+	var until sql.NullString
+	if !mute.Until.IsZero() {
+		until = sql.NullString{String: mute.Until.UTC().Format(time.RFC3339), Valid: true}
+	}
+	_, err := d.ctx.Exec(`
+	INSERT OR REPLACE INTO feed_mutes(
+		feed_id,
+		feed_title,
+		until
+	)
+	VALUES(?,?,?)`, mute.FeedID, mute.FeedTitle, until)
+	return err
+}
+
+func (d db) GetFeedMutes(at time.Time) ([]models.FeedMute, error) {
+	results := make([]models.FeedMute, 0)
+	res, err := d.ctx.Query("SELECT feed_id, feed_title, until FROM feed_mutes where until IS NULL OR until>? ORDER BY feed_title", at.UTC().Format(time.RFC3339))
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var mute models.FeedMute
+		var until sql.NullString
+		if err := res.Scan(&mute.FeedID, &mute.FeedTitle, &until); err != nil {
+			continue
+		}
+		if until.Valid {
+			mute.Until, err = time.Parse(time.RFC3339, until.String)
+			if err != nil {
+				continue
+			}
+		}
+		results = append(results, mute)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) DeleteFeedMute(feedID int64) error {
+	_, err := d.ctx.Exec(`
+	DELETE from feed_mutes where feed_id=?
+	`, feedID)
+	return err
+}
+
+func (d db) DeleteExpiredFeedMutes(before time.Time) (int64, error) {
+	res, err := d.ctx.Exec(`
+	DELETE from feed_mutes where until IS NOT NULL AND until<=?
+	`, before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Expiry times are stored in UTC so they can be compared as strings
+func (d db) InsertPendingDelete(pending models.PendingDelete) error {
+	_, err := d.ctx.Exec(`
+	INSERT OR REPLACE INTO pending_deletes(
+		telegram_id,
+		entry_id,
+		sent_time,
+		updated,
+		delete_read,
+		marked_read,
+		expires
+	)
+	VALUES(?,?,?,?,?,?,?)`,
+		pending.Entry.TelegramID,
+		pending.Entry.ID,
+		pending.Entry.SentTime.Format(time.RFC3339),
+		pending.Entry.UpdatedTime.Format(time.RFC3339),
+		pending.Entry.DeleteRead,
+		pending.MarkedRead,
+		pending.Expires.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+func (d db) GetPendingDelete(telegramID int) (models.PendingDelete, error) {
+	res, err := d.queryPendingDeletes("SELECT telegram_id, entry_id, sent_time, updated, delete_read, marked_read, expires FROM pending_deletes where telegram_id=?", telegramID)
+	if err != nil {
+		return models.PendingDelete{}, err
+	}
+	if len(res) == 0 {
+		return models.PendingDelete{}, store.ErrNotFound
+	}
+	return res[0], nil
+}
+
+func (d db) GetExpiredPendingDeletes(before time.Time) ([]models.PendingDelete, error) {
+	return d.queryPendingDeletes("SELECT telegram_id, entry_id, sent_time, updated, delete_read, marked_read, expires FROM pending_deletes where expires<=? ORDER BY expires", before.UTC().Format(time.RFC3339))
+}
+
+func (d db) DeletePendingDelete(telegramID int) error {
+	_, err := d.ctx.Exec(`
+	DELETE from pending_deletes where telegram_id=?
+	`, telegramID)
+	return err
+}
+
+// queryPendingDeletes runs a query returning pending_deletes rows
+func (d db) queryPendingDeletes(query string, args ...any) ([]models.PendingDelete, error) {
+	results := make([]models.PendingDelete, 0)
+	res, err := d.ctx.Query(query, args...)
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var pending models.PendingDelete
+		var sent_time, updated_time, expires string
+		if err := res.Scan(&pending.Entry.TelegramID, &pending.Entry.ID, &sent_time, &updated_time, &pending.Entry.DeleteRead, &pending.MarkedRead, &expires); err != nil {
+			continue
+		}
+		if pending.Entry.SentTime, err = time.Parse(time.RFC3339, sent_time); err != nil {
+			continue
+		}
+		if pending.Entry.UpdatedTime, err = time.Parse(time.RFC3339, updated_time); err != nil {
+			continue
+		}
+		if pending.Expires, err = time.Parse(time.RFC3339, expires); err != nil {
+			continue
+		}
+		results = append(results, pending)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) InsertSelection(selection models.Selection) error {
+	_, err := d.ctx.Exec(`
+	INSERT OR REPLACE INTO selected_entries(
+		entry_id,
+		telegram_id,
+		added
+	)
+	VALUES(?,?,?)`, selection.EntryID, selection.TelegramID, selection.Added.UTC().Format(time.RFC3339))
+	return err
+}
+
+func (d db) GetSelection() ([]models.Selection, error) {
+	results := make([]models.Selection, 0)
+	res, err := d.ctx.Query("SELECT entry_id, telegram_id, added FROM selected_entries ORDER BY added, entry_id")
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var selection models.Selection
+		var added string
+		if err := res.Scan(&selection.EntryID, &selection.TelegramID, &added); err != nil {
+			continue
+		}
+		selection.Added, err = time.Parse(time.RFC3339, added)
+		if err != nil {
+			continue
+		}
+		results = append(results, selection)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) DeleteSelection(entryID int64) error {
+	_, err := d.ctx.Exec(`
+	DELETE from selected_entries where entry_id=?
+	`, entryID)
+	return err
+}
+
+func (d db) InsertPin(pin models.Pin) error {
+	_, err := d.ctx.Exec(`
+	INSERT OR REPLACE INTO pins(
+		entry_id,
+		telegram_id,
+		pinned
+	)
+	VALUES(?,?,?)`, pin.EntryID, pin.TelegramID, pin.Pinned.UTC().Format(time.RFC3339))
+	return err
+}
+
+func (d db) GetPins() ([]models.Pin, error) {
+	results := make([]models.Pin, 0)
+	res, err := d.ctx.Query("SELECT entry_id, telegram_id, pinned FROM pins ORDER BY pinned, entry_id")
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var pin models.Pin
+		var pinned string
+		if err := res.Scan(&pin.EntryID, &pin.TelegramID, &pinned); err != nil {
+			continue
+		}
+		pin.Pinned, err = time.Parse(time.RFC3339, pinned)
+		if err != nil {
+			continue
+		}
+		results = append(results, pin)
+	}
+
+	return results, res.Err()
+}
+
+func (d db) DeletePin(entryID int64) error {
+	_, err := d.ctx.Exec(`
+	DELETE from pins where entry_id=?
+	`, entryID)
+	return err
+}
+
+func (d db) GetEntryByTelegramID(id int) (models.Message, error) {
+	var msg models.Message
+	var sent_time, updated_time string
+	err := d.ctx.QueryRow("SELECT id, telegram_id, sent_time, updated, delete_read, fingerprint FROM entries where telegram_id=?", id).Scan(&msg.ID, &msg.TelegramID, &sent_time, &updated_time, &msg.DeleteRead, &msg.Fingerprint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return msg, store.ErrNotFound
+	}
+	if err != nil {
+		return msg, err
+	}
+
+	msg.SentTime, err = time.Parse(time.RFC3339, sent_time)
+	if err != nil {
+		return msg, err
+	}
+
+	msg.UpdatedTime, err = time.Parse(time.RFC3339, updated_time)
+	if err != nil {
+		return msg, err
+	}
+
+	return msg, nil
+}
+
+func (d db) InsertNote(note models.Note) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO notes(
+		entry_id,
+		text,
+		created
+	)
+	VALUES(?,?,?)`, note.EntryID, note.Text, note.Created.Format(time.RFC3339))
+	return err
+}
+
+func (d db) GetNotes() ([]models.Note, error) {
+	return d.queryNotes("SELECT id, entry_id, text, created FROM notes ORDER BY entry_id, id")
+}
+
+func (d db) GetNotesByEntryID(entryID int64) ([]models.Note, error) {
+	return d.queryNotes("SELECT id, entry_id, text, created FROM notes where entry_id=? ORDER BY id", entryID)
+}
+
+func (d db) queryNotes(query string, args ...any) ([]models.Note, error) {
+	results := make([]models.Note, 0)
+	res, err := d.ctx.Query(query, args...)
+	if err != nil {
+		return results, err
+	}
+	defer res.Close()
+
+	for res.Next() {
+		var note models.Note
+		var created string
+		if err := res.Scan(&note.ID, &note.EntryID, &note.Text, &created); err != nil {
+			continue
+		}
+		note.Created, err = time.Parse(time.RFC3339, created)
+		if err != nil {
+			continue
+		}
+		results = append(results, note)
+	}
+
+	return results, res.Err()
+}
+
+// Token expiry times are stored in UTC so they can be compared as strings
+func (d db) InsertCallbackToken(token models.CallbackToken) error {
+	_, err := d.ctx.Exec(`
+	INSERT INTO callback_tokens(
+		token,
+		action,
+		entry_ids,
+		page,
+		chat_id,
+		param,
+		single_use,
+		expires
+	)
+	VALUES(?,?,?,?,?,?,?,?)`, token.Token, token.Action, joinIDs(token.EntryIDs), token.Page, token.ChatID, token.Param, token.SingleUse, token.Expires.UTC().Format(time.RFC3339))
+	return err
+}
+
+func (d db) GetCallbackToken(token string) (models.CallbackToken, error) {
+	var result models.CallbackToken
+	var entryIDs, expires string
+	err := d.ctx.QueryRow("SELECT token, action, entry_ids, page, chat_id, param, single_use, expires FROM callback_tokens where token=?", token).Scan(
+		&result.Token, &result.Action, &entryIDs, &result.Page, &result.ChatID, &result.Param, &result.SingleUse, &expires,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result, store.ErrNotFound
+	}
+	if err != nil {
+		return result, err
+	}
+
+	result.EntryIDs, err = splitIDs(entryIDs)
+	if err != nil {
+		return result, err
+	}
+
+	result.Expires, err = time.Parse(time.RFC3339, expires)
+	return result, err
+}
+
+func (d db) DeleteCallbackToken(token string) error {
+	_, err := d.ctx.Exec(`
+	DELETE from callback_tokens where token=?
+	`, token)
+	return err
+}
+
+func (d db) DeleteExpiredCallbackTokens(before time.Time) (int64, error) {
+	res, err := d.ctx.Exec(`
+	DELETE from callback_tokens where expires<?
+	`, before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// joinIDs stores a list of IDs as a comma separated string
+func joinIDs(ids []int64) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitIDs(joined string) ([]int64, error) {
+	ids := make([]int64, 0)
+	if joined == "" {
+		return ids, nil
+	}
+	for _, part := range strings.Split(joined, ",") {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,24 @@
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	// Migrations live at the root of the repo, where main embeds them
+	EmbedMigrations = os.DirFS("../..")
+
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := open(filepath.Join(t.TempDir(), "store.db"))
+		if err != nil {
+			t.Fatalf("Opening store: %v", err)
+		}
+		t.Cleanup(func() { s.ctx.Close() })
+		return s
+	})
+}
@@ -1,18 +1,65 @@
 package store
 
 import (
+	"errors"
 	"time"
 
 	"go.jloh.dev/miniflux-telegram-bot/models"
 )
 
+// ErrNotFound is returned when a requested record doesn't exist
+var ErrNotFound = errors.New("store: not found")
+
+// TimePrecision is how precisely stores keep times. SQLite keeps them as RFC 3339 text,
+// so every store truncates to whole seconds to behave the same.
+const TimePrecision = time.Second
+
 // Storage interface for storing a mapping of
 // Miniflux IDs to Telegram messages
 type Store interface {
-	GetEntries() ([]models.Message, error)             // Get all entries in DB
-	GetEntry(id int) (models.Message, error)           // Get a single entry in the DB
-	InsertEntry(models.Message) error                  // Insert a new entry into the DB
-	UpdateEntryTime(id int64, updated time.Time) error // Update the entry updated time
-	DeleteEntryByID(id int64) error                    // Delete a entry in the DB by Miniflux ID
-	DeleteEntryByTelegramID(id int) error              // Delete a entry in the DB by its Telegram ID
+	GetEntries() ([]models.Message, error)                     // Get all entries in DB
+	GetEntry(id int64) (models.Message, error)                 // Get a single entry in the DB by Miniflux ID, ErrNotFound if none
+	InsertEntry(models.Message) error                          // Insert a new entry into the DB
+	UpdateEntryTime(id int64, updated time.Time) error         // Update the entry updated time
+	UpdateEntryFingerprint(id int64, fingerprint string) error // Update the fingerprint of the entry's rendered message
+	DeleteEntryByID(id int64) error                            // Delete a entry in the DB by Miniflux ID
+	DeleteEntryByTelegramID(id int) error                      // Delete a entry in the DB by its Telegram ID
+	GetEntryByTelegramID(id int) (models.Message, error)       // Get a single entry in the DB by its Telegram ID, ErrNotFound if none
+
+	GetSetting(key string) (string, error)     // Get a bot setting, ErrNotFound if it isn't set
+	SetSetting(key string, value string) error // Set a bot setting, replacing any existing value
+
+	GetTelegraphPage(entryID int64) (models.TelegraphPage, error) // Get the Telegraph page for an entry, ErrNotFound if none
+	InsertTelegraphPage(models.TelegraphPage) error               // Save a published Telegraph page
+
+	InsertSnooze(models.Snooze) error                        // Save a snoozed entry, replacing any existing snooze for it
+	GetDueSnoozes(before time.Time) ([]models.Snooze, error) // Get snoozed entries due to be sent before a time
+	DeleteSnooze(entryID int64) error                        // Delete a snoozed entry
+
+	InsertFeedMute(models.FeedMute) error                   // Save a muted feed, replacing any existing mute for it
+	GetFeedMutes(at time.Time) ([]models.FeedMute, error)   // Get feeds which are muted at a time
+	DeleteFeedMute(feedID int64) error                      // Delete a muted feed
+	DeleteExpiredFeedMutes(before time.Time) (int64, error) // Delete mutes which ended before a time, returning how many were removed
+
+	InsertPendingDelete(models.PendingDelete) error                            // Save a deleted message that can still be restored
+	GetPendingDelete(telegramID int) (models.PendingDelete, error)             // Get a deleted message by its Telegram ID, ErrNotFound if none
+	GetExpiredPendingDeletes(before time.Time) ([]models.PendingDelete, error) // Get deleted messages whose undo window ended before a time
+	DeletePendingDelete(telegramID int) error                                  // Delete a deleted message once it's restored or gone for good
+
+	InsertSelection(models.Selection) error    // Add an entry to the bulk action selection
+	GetSelection() ([]models.Selection, error) // Get all selected entries, in the order they were selected
+	DeleteSelection(entryID int64) error       // Remove an entry from the selection
+
+	InsertPin(models.Pin) error     // Save a pinned entry message, replacing any existing pin for the entry
+	GetPins() ([]models.Pin, error) // Get all pinned entry messages
+	DeletePin(entryID int64) error  // Delete a pinned entry message
+
+	InsertNote(models.Note) error                           // Save a note attached to an entry
+	GetNotes() ([]models.Note, error)                       // Get all notes, grouped by entry
+	GetNotesByEntryID(entryID int64) ([]models.Note, error) // Get the notes attached to an entry
+
+	InsertCallbackToken(models.CallbackToken) error              // Save a callback token's payload
+	GetCallbackToken(token string) (models.CallbackToken, error) // Get a callback token's payload, ErrNotFound if none
+	DeleteCallbackToken(token string) error                      // Delete a callback token
+	DeleteExpiredCallbackTokens(before time.Time) (int64, error) // Delete tokens which expired before a time, returning how many were removed
 }
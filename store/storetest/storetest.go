@@ -0,0 +1,713 @@
+// Package storetest checks a store.Store implementation behaves the way the bot expects
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+)
+
+// Times are whole seconds in UTC, as that's the precision every backend keeps
+var base = time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+func at(minutes int) time.Time {
+	return base.Add(time.Duration(minutes) * time.Minute)
+}
+
+// Run runs the suite against stores from newStore, which must return an empty store each call
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s store.Store)
+	}{
+		{"Entries", testEntries},
+		{"EntryNotFound", testEntryNotFound},
+		{"EntryUpdates", testEntryUpdates},
+		{"EntryDeletes", testEntryDeletes},
+		{"Settings", testSettings},
+		{"TelegraphPages", testTelegraphPages},
+		{"Snoozes", testSnoozes},
+		{"FeedMutes", testFeedMutes},
+		{"PendingDeletes", testPendingDeletes},
+		{"Selection", testSelection},
+		{"Pins", testPins},
+		{"Notes", testNotes},
+		{"CallbackTokens", testCallbackTokens},
+		{"TimePrecision", testTimePrecision},
+		{"Concurrency", testConcurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func testEntries(t *testing.T, s store.Store) {
+	entries, err := s.GetEntries()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("GetEntries on an empty store: got %v, %v, want no entries", entries, err)
+	}
+
+	// Miniflux IDs don't fit in 32 bits on busy instances
+	msg := models.Message{ID: 1 << 40, TelegramID: 7, SentTime: at(0), UpdatedTime: at(5), DeleteRead: true, Fingerprint: "abc"}
+	other := models.Message{ID: 2, TelegramID: 8, SentTime: at(1), UpdatedTime: at(1)}
+	for _, m := range []models.Message{msg, other} {
+		if err := s.InsertEntry(m); err != nil {
+			t.Fatalf("InsertEntry(%d): %v", m.ID, err)
+		}
+	}
+
+	if err := s.InsertEntry(msg); err == nil {
+		t.Error("InsertEntry with an existing ID should fail")
+	}
+
+	got, err := s.GetEntry(msg.ID)
+	if err != nil {
+		t.Fatalf("GetEntry: %v", err)
+	}
+	assertEqual(t, "GetEntry", normaliseMessage(got), msg)
+
+	got, err = s.GetEntryByTelegramID(other.TelegramID)
+	if err != nil {
+		t.Fatalf("GetEntryByTelegramID: %v", err)
+	}
+	assertEqual(t, "GetEntryByTelegramID", normaliseMessage(got), other)
+
+	entries, err = s.GetEntries()
+	if err != nil {
+		t.Fatalf("GetEntries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("GetEntries: got %d entries, want 2", len(entries))
+	}
+	byID := make(map[int64]models.Message)
+	for _, e := range entries {
+		byID[e.ID] = normaliseMessage(e)
+	}
+	assertEqual(t, "GetEntries", byID, map[int64]models.Message{msg.ID: msg, other.ID: other})
+}
+
+func testTimePrecision(t *testing.T, s store.Store) {
+	subSecond := at(0).Add(600 * time.Millisecond)
+
+	msg := models.Message{ID: 1, TelegramID: 7, SentTime: subSecond, UpdatedTime: subSecond}
+	mustInsertEntries(t, s, msg)
+	got, err := s.GetEntry(msg.ID)
+	if err != nil {
+		t.Fatalf("GetEntry: %v", err)
+	}
+	assertEqual(t, "Entry times are kept to the second", normaliseMessage(got), models.Message{ID: 1, TelegramID: 7, SentTime: at(0), UpdatedTime: at(0)})
+
+	if err := s.InsertSnooze(models.Snooze{EntryID: 1, Until: subSecond}); err != nil {
+		t.Fatalf("InsertSnooze: %v", err)
+	}
+	due, err := s.GetDueSnoozes(at(0))
+	if err != nil {
+		t.Fatalf("GetDueSnoozes: %v", err)
+	}
+	if len(due) != 1 {
+		t.Errorf("A snooze ending within the second is due: got %d snoozes, want 1", len(due))
+	}
+
+	token := models.CallbackToken{Token: "abc", Action: "r", ChatID: 1, Expires: subSecond}
+	if err := s.InsertCallbackToken(token); err != nil {
+		t.Fatalf("InsertCallbackToken: %v", err)
+	}
+	removed, err := s.DeleteExpiredCallbackTokens(at(0).Add(300 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("DeleteExpiredCallbackTokens: %v", err)
+	}
+	if removed != 0 {
+		t.Errorf("A token expiring within the second isn't expired yet: got %d removed, want 0", removed)
+	}
+}
+
+func testEntryNotFound(t *testing.T, s store.Store) {
+	if _, err := s.GetEntry(1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetEntry for a missing entry: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetEntryByTelegramID(1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetEntryByTelegramID for a missing entry: got %v, want ErrNotFound", err)
+	}
+
+	var tests = []struct {
+		call        func() error
+		explanation string
+	}{
+		{func() error { return s.UpdateEntryTime(1, at(0)) }, "Updating the time of a missing entry"},
+		{func() error { return s.UpdateEntryFingerprint(1, "abc") }, "Updating the fingerprint of a missing entry"},
+		{func() error { return s.DeleteEntryByID(1) }, "Deleting a missing entry by ID"},
+		{func() error { return s.DeleteEntryByTelegramID(1) }, "Deleting a missing entry by Telegram ID"},
+	}
+
+	for _, tt := range tests {
+		if err := tt.call(); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.explanation, err)
+		}
+	}
+
+	if _, err := s.GetEntry(1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Updating a missing entry shouldn't create it, got %v", err)
+	}
+}
+
+func testEntryUpdates(t *testing.T, s store.Store) {
+	msg := models.Message{ID: 1, TelegramID: 10, SentTime: at(0), UpdatedTime: at(0), DeleteRead: true, Fingerprint: "old"}
+	other := models.Message{ID: 2, TelegramID: 20, SentTime: at(0), UpdatedTime: at(0), Fingerprint: "other"}
+	mustInsertEntries(t, s, msg, other)
+
+	if err := s.UpdateEntryTime(msg.ID, at(30)); err != nil {
+		t.Fatalf("UpdateEntryTime: %v", err)
+	}
+	if err := s.UpdateEntryFingerprint(msg.ID, "new"); err != nil {
+		t.Fatalf("UpdateEntryFingerprint: %v", err)
+	}
+
+	msg.UpdatedTime = at(30)
+	msg.Fingerprint = "new"
+	got, err := s.GetEntry(msg.ID)
+	if err != nil {
+		t.Fatalf("GetEntry: %v", err)
+	}
+	assertEqual(t, "Updated entry", normaliseMessage(got), msg)
+
+	got, err = s.GetEntry(other.ID)
+	if err != nil {
+		t.Fatalf("GetEntry: %v", err)
+	}
+	assertEqual(t, "Other entries are left alone", normaliseMessage(got), other)
+}
+
+func testEntryDeletes(t *testing.T, s store.Store) {
+	mustInsertEntries(t, s,
+		models.Message{ID: 1, TelegramID: 10, SentTime: at(0), UpdatedTime: at(0)},
+		models.Message{ID: 2, TelegramID: 20, SentTime: at(0), UpdatedTime: at(0)},
+		models.Message{ID: 3, TelegramID: 30, SentTime: at(0), UpdatedTime: at(0)},
+	)
+
+	if err := s.DeleteEntryByID(1); err != nil {
+		t.Fatalf("DeleteEntryByID: %v", err)
+	}
+	if err := s.DeleteEntryByTelegramID(20); err != nil {
+		t.Fatalf("DeleteEntryByTelegramID: %v", err)
+	}
+
+	entries, err := s.GetEntries()
+	if err != nil {
+		t.Fatalf("GetEntries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != 3 {
+		t.Errorf("Only entry 3 should be left, got %v", entries)
+	}
+}
+
+func testSettings(t *testing.T, s store.Store) {
+	if _, err := s.GetSetting("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSetting for a missing key: got %v, want ErrNotFound", err)
+	}
+
+	var tests = []struct {
+		key         string
+		value       string
+		explanation string
+	}{
+		{"last_poll", "2026-10-18T09:00:00Z", "A new setting"},
+		{"last_poll", "2026-10-18T10:00:00Z", "Replacing a setting"},
+		{"empty", "", "An empty value is still set"},
+	}
+
+	for _, tt := range tests {
+		if err := s.SetSetting(tt.key, tt.value); err != nil {
+			t.Fatalf("%s: SetSetting: %v", tt.explanation, err)
+		}
+		value, err := s.GetSetting(tt.key)
+		if err != nil {
+			t.Fatalf("%s: GetSetting: %v", tt.explanation, err)
+		}
+		if value != tt.value {
+			t.Errorf("%s: got %q, want %q", tt.explanation, value, tt.value)
+		}
+	}
+}
+
+func testTelegraphPages(t *testing.T, s store.Store) {
+	if _, err := s.GetTelegraphPage(1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetTelegraphPage for a missing page: got %v, want ErrNotFound", err)
+	}
+
+	page := models.TelegraphPage{EntryID: 1, Path: "First-10-18", URL: "https://telegra.ph/First-10-18", Created: at(0)}
+	if err := s.InsertTelegraphPage(page); err != nil {
+		t.Fatalf("InsertTelegraphPage: %v", err)
+	}
+	// Republishing an entry replaces its page
+	page.Path, page.URL, page.Created = "First-10-18-2", "https://telegra.ph/First-10-18-2", at(10)
+	if err := s.InsertTelegraphPage(page); err != nil {
+		t.Fatalf("InsertTelegraphPage again: %v", err)
+	}
+
+	got, err := s.GetTelegraphPage(1)
+	if err != nil {
+		t.Fatalf("GetTelegraphPage: %v", err)
+	}
+	got.Created = got.Created.UTC()
+	assertEqual(t, "GetTelegraphPage", got, page)
+}
+
+func testSnoozes(t *testing.T, s store.Store) {
+	for _, snooze := range []models.Snooze{
+		{EntryID: 1, Until: at(20), DeleteRead: true},
+		{EntryID: 2, Until: at(10)},
+		{EntryID: 3, Until: at(30)},
+		{EntryID: 3, Until: at(60), DeleteRead: true},
+	} {
+		if err := s.InsertSnooze(snooze); err != nil {
+			t.Fatalf("InsertSnooze(%d): %v", snooze.EntryID, err)
+		}
+	}
+
+	var tests = []struct {
+		before      time.Time
+		expected    []int64
+		explanation string
+	}{
+		{at(5), []int64{}, "Nothing is due yet"},
+		{at(10), []int64{2}, "Snoozes due exactly now are included"},
+		{at(45), []int64{2, 1}, "Ordered by when they're due, and a replaced snooze uses its new time"},
+		{at(60), []int64{2, 1, 3}, "Everything is due"},
+	}
+
+	for _, tt := range tests {
+		snoozes, err := s.GetDueSnoozes(tt.before)
+		if err != nil {
+			t.Fatalf("%s: GetDueSnoozes: %v", tt.explanation, err)
+		}
+		ids := make([]int64, 0)
+		for _, snooze := range snoozes {
+			ids = append(ids, snooze.EntryID)
+		}
+		assertEqual(t, tt.explanation, ids, tt.expected)
+	}
+
+	snoozes, _ := s.GetDueSnoozes(at(60))
+	if len(snoozes) == 3 {
+		snoozes[2].Until = snoozes[2].Until.UTC()
+		assertEqual(t, "Replaced snooze", snoozes[2], models.Snooze{EntryID: 3, Until: at(60), DeleteRead: true})
+	}
+
+	if err := s.DeleteSnooze(2); err != nil {
+		t.Fatalf("DeleteSnooze: %v", err)
+	}
+	if err := s.DeleteSnooze(99); err != nil {
+		t.Errorf("DeleteSnooze for a missing snooze: unexpected error %v", err)
+	}
+	snoozes, err := s.GetDueSnoozes(at(60))
+	if err != nil {
+		t.Fatalf("GetDueSnoozes: %v", err)
+	}
+	if len(snoozes) != 2 {
+		t.Errorf("Deleted snooze is still due: %v", snoozes)
+	}
+}
+
+func testFeedMutes(t *testing.T, s store.Store) {
+	for _, mute := range []models.FeedMute{
+		{FeedID: 1, FeedTitle: "Lobsters", Until: at(60)},
+		{FeedID: 2, FeedTitle: "Hacker News"},
+		{FeedID: 3, FeedTitle: "Slashdot", Until: at(10)},
+		{FeedID: 1, FeedTitle: "Lobste.rs", Until: at(120)},
+	} {
+		if err := s.InsertFeedMute(mute); err != nil {
+			t.Fatalf("InsertFeedMute(%d): %v", mute.FeedID, err)
+		}
+	}
+
+	var tests = []struct {
+		at          time.Time
+		expected    []string
+		explanation string
+	}{
+		{at(0), []string{"Hacker News", "Lobste.rs", "Slashdot"}, "Ordered by title, with a replaced mute using its new title"},
+		{at(10), []string{"Hacker News", "Lobste.rs"}, "A mute has ended once its time is reached"},
+		{at(90), []string{"Hacker News", "Lobste.rs"}, "A replaced mute uses its new end time"},
+		{at(1000000), []string{"Hacker News"}, "Mutes without an end time never end"},
+	}
+
+	for _, tt := range tests {
+		mutes, err := s.GetFeedMutes(tt.at)
+		if err != nil {
+			t.Fatalf("%s: GetFeedMutes: %v", tt.explanation, err)
+		}
+		titles := make([]string, 0)
+		for _, mute := range mutes {
+			titles = append(titles, mute.FeedTitle)
+		}
+		assertEqual(t, tt.explanation, titles, tt.expected)
+	}
+
+	mutes, _ := s.GetFeedMutes(at(0))
+	if len(mutes) == 3 && !mutes[0].Until.IsZero() {
+		t.Errorf("A mute without an end time should have a zero Until, got %v", mutes[0].Until)
+	}
+
+	removed, err := s.DeleteExpiredFeedMutes(at(10))
+	if err != nil {
+		t.Fatalf("DeleteExpiredFeedMutes: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("DeleteExpiredFeedMutes: removed %d, want 1", removed)
+	}
+	removed, err = s.DeleteExpiredFeedMutes(at(1000000))
+	if err != nil {
+		t.Fatalf("DeleteExpiredFeedMutes: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("DeleteExpiredFeedMutes shouldn't remove mutes without an end time, removed %d, want 1", removed)
+	}
+
+	if err := s.DeleteFeedMute(2); err != nil {
+		t.Fatalf("DeleteFeedMute: %v", err)
+	}
+	mutes, err = s.GetFeedMutes(at(0))
+	if err != nil {
+		t.Fatalf("GetFeedMutes: %v", err)
+	}
+	if len(mutes) != 0 {
+		t.Errorf("Every mute should be gone, got %v", mutes)
+	}
+}
+
+func testPendingDeletes(t *testing.T, s store.Store) {
+	if _, err := s.GetPendingDelete(1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetPendingDelete for a missing message: got %v, want ErrNotFound", err)
+	}
+
+	first := models.PendingDelete{
+		Entry:      models.Message{ID: 1 << 40, TelegramID: 10, SentTime: at(0), UpdatedTime: at(1), DeleteRead: true},
+		MarkedRead: true,
+		Expires:    at(20),
+	}
+	second := models.PendingDelete{
+		Entry:   models.Message{ID: 2, TelegramID: 20, SentTime: at(0), UpdatedTime: at(0)},
+		Expires: at(10),
+	}
+	for _, pending := range []models.PendingDelete{first, second} {
+		if err := s.InsertPendingDelete(pending); err != nil {
+			t.Fatalf("InsertPendingDelete(%d): %v", pending.Entry.TelegramID, err)
+		}
+	}
+
+	got, err := s.GetPendingDelete(10)
+	if err != nil {
+		t.Fatalf("GetPendingDelete: %v", err)
+	}
+	assertEqual(t, "GetPendingDelete", normalisePendingDelete(got), first)
+
+	var tests = []struct {
+		before      time.Time
+		expected    []int
+		explanation string
+	}{
+		{at(5), []int{}, "Nothing has expired yet"},
+		{at(10), []int{20}, "Deletes expiring exactly now are included"},
+		{at(30), []int{20, 10}, "Ordered by when they expire"},
+	}
+
+	for _, tt := range tests {
+		expired, err := s.GetExpiredPendingDeletes(tt.before)
+		if err != nil {
+			t.Fatalf("%s: GetExpiredPendingDeletes: %v", tt.explanation, err)
+		}
+		ids := make([]int, 0)
+		for _, pending := range expired {
+			ids = append(ids, pending.Entry.TelegramID)
+		}
+		assertEqual(t, tt.explanation, ids, tt.expected)
+	}
+
+	if err := s.DeletePendingDelete(10); err != nil {
+		t.Fatalf("DeletePendingDelete: %v", err)
+	}
+	if _, err := s.GetPendingDelete(10); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetPendingDelete after deleting it: got %v, want ErrNotFound", err)
+	}
+}
+
+func testSelection(t *testing.T, s store.Store) {
+	for _, selection := range []models.Selection{
+		{EntryID: 3, TelegramID: 30, Added: at(5)},
+		{EntryID: 2, TelegramID: 20, Added: at(0)},
+		{EntryID: 1, TelegramID: 10, Added: at(5)},
+	} {
+		if err := s.InsertSelection(selection); err != nil {
+			t.Fatalf("InsertSelection(%d): %v", selection.EntryID, err)
+		}
+	}
+
+	selection, err := s.GetSelection()
+	if err != nil {
+		t.Fatalf("GetSelection: %v", err)
+	}
+	for i := range selection {
+		selection[i].Added = selection[i].Added.UTC()
+	}
+	assertEqual(t, "Ordered by when they were selected, then entry ID", selection, []models.Selection{
+		{EntryID: 2, TelegramID: 20, Added: at(0)},
+		{EntryID: 1, TelegramID: 10, Added: at(5)},
+		{EntryID: 3, TelegramID: 30, Added: at(5)},
+	})
+
+	if err := s.DeleteSelection(1); err != nil {
+		t.Fatalf("DeleteSelection: %v", err)
+	}
+	if err := s.DeleteSelection(99); err != nil {
+		t.Errorf("DeleteSelection for a missing entry: unexpected error %v", err)
+	}
+	selection, err = s.GetSelection()
+	if err != nil {
+		t.Fatalf("GetSelection: %v", err)
+	}
+	if len(selection) != 2 {
+		t.Errorf("Deleted entry is still selected: %v", selection)
+	}
+}
+
+func testPins(t *testing.T, s store.Store) {
+	for _, pin := range []models.Pin{
+		{EntryID: 2, TelegramID: 20, Pinned: at(10)},
+		{EntryID: 1, TelegramID: 10, Pinned: at(0)},
+		{EntryID: 1, TelegramID: 11, Pinned: at(20)},
+	} {
+		if err := s.InsertPin(pin); err != nil {
+			t.Fatalf("InsertPin(%d): %v", pin.EntryID, err)
+		}
+	}
+
+	pins, err := s.GetPins()
+	if err != nil {
+		t.Fatalf("GetPins: %v", err)
+	}
+	for i := range pins {
+		pins[i].Pinned = pins[i].Pinned.UTC()
+	}
+	assertEqual(t, "Ordered by when they were pinned, with a re-pinned entry replacing its old pin", pins, []models.Pin{
+		{EntryID: 2, TelegramID: 20, Pinned: at(10)},
+		{EntryID: 1, TelegramID: 11, Pinned: at(20)},
+	})
+
+	if err := s.DeletePin(1); err != nil {
+		t.Fatalf("DeletePin: %v", err)
+	}
+	if err := s.DeletePin(99); err != nil {
+		t.Errorf("DeletePin for a missing pin: unexpected error %v", err)
+	}
+	pins, err = s.GetPins()
+	if err != nil {
+		t.Fatalf("GetPins: %v", err)
+	}
+	if len(pins) != 1 || pins[0].EntryID != 2 {
+		t.Errorf("Only entry 2 should be pinned, got %v", pins)
+	}
+}
+
+func testNotes(t *testing.T, s store.Store) {
+	notes, err := s.GetNotesByEntryID(1)
+	if err != nil || len(notes) != 0 {
+		t.Fatalf("GetNotesByEntryID with no notes: got %v, %v, want no notes", notes, err)
+	}
+
+	for _, note := range []models.Note{
+		{EntryID: 2, Text: "second entry", Created: at(0)},
+		{EntryID: 1, Text: "first", Created: at(1)},
+		{EntryID: 1, Text: "second", Created: at(2)},
+	} {
+		if err := s.InsertNote(note); err != nil {
+			t.Fatalf("InsertNote(%q): %v", note.Text, err)
+		}
+	}
+
+	notes, err = s.GetNotes()
+	if err != nil {
+		t.Fatalf("GetNotes: %v", err)
+	}
+	texts := make([]string, 0)
+	for _, note := range notes {
+		texts = append(texts, note.Text)
+	}
+	assertEqual(t, "Grouped by entry, in the order they were added", texts, []string{"first", "second", "second entry"})
+
+	notes, err = s.GetNotesByEntryID(1)
+	if err != nil {
+		t.Fatalf("GetNotesByEntryID: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("GetNotesByEntryID: got %d notes, want 2", len(notes))
+	}
+	if notes[0].ID == 0 || notes[0].ID >= notes[1].ID {
+		t.Errorf("Notes should be given increasing IDs, got %d then %d", notes[0].ID, notes[1].ID)
+	}
+	notes[0].Created = notes[0].Created.UTC()
+	assertEqual(t, "GetNotesByEntryID", notes[0], models.Note{ID: notes[0].ID, EntryID: 1, Text: "first", Created: at(1)})
+}
+
+func testCallbackTokens(t *testing.T, s store.Store) {
+	if _, err := s.GetCallbackToken("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetCallbackToken for a missing token: got %v, want ErrNotFound", err)
+	}
+
+	var tests = []struct {
+		token       models.CallbackToken
+		explanation string
+	}{
+		{models.CallbackToken{Token: "bulk", Action: "br", EntryIDs: []int64{3, 1 << 40, 2}, ChatID: -100123, SingleUse: true, Expires: at(10)}, "Entry IDs keep their order"},
+		{models.CallbackToken{Token: "page", Action: "p", EntryIDs: []int64{}, Page: 2, ChatID: 1, Param: "starred", Expires: at(20)}, "No entry IDs"},
+	}
+
+	for _, tt := range tests {
+		if err := s.InsertCallbackToken(tt.token); err != nil {
+			t.Fatalf("%s: InsertCallbackToken: %v", tt.explanation, err)
+		}
+		got, err := s.GetCallbackToken(tt.token.Token)
+		if err != nil {
+			t.Fatalf("%s: GetCallbackToken: %v", tt.explanation, err)
+		}
+		assertEqual(t, tt.explanation, normaliseCallbackToken(got), normaliseCallbackToken(tt.token))
+	}
+
+	if err := s.InsertCallbackToken(tests[0].token); err == nil {
+		t.Error("InsertCallbackToken with an existing token should fail")
+	}
+
+	// Tokens expiring exactly now are still valid
+	removed, err := s.DeleteExpiredCallbackTokens(at(10))
+	if err != nil {
+		t.Fatalf("DeleteExpiredCallbackTokens: %v", err)
+	}
+	if removed != 0 {
+		t.Errorf("DeleteExpiredCallbackTokens at the expiry time: removed %d, want 0", removed)
+	}
+	removed, err = s.DeleteExpiredCallbackTokens(at(15))
+	if err != nil {
+		t.Fatalf("DeleteExpiredCallbackTokens: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("DeleteExpiredCallbackTokens: removed %d, want 1", removed)
+	}
+	if _, err := s.GetCallbackToken("bulk"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expired token should be gone, got %v", err)
+	}
+
+	if err := s.DeleteCallbackToken("page"); err != nil {
+		t.Fatalf("DeleteCallbackToken: %v", err)
+	}
+	if _, err := s.GetCallbackToken("page"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Deleted token should be gone, got %v", err)
+	}
+}
+
+// testConcurrency mirrors the bot's goroutines polling, syncing and handling buttons at once
+func testConcurrency(t *testing.T, s store.Store) {
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 1; i <= workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := int64(i)
+			if err := s.InsertEntry(models.Message{ID: id, TelegramID: i, SentTime: at(0), UpdatedTime: at(0)}); err != nil {
+				errs <- fmt.Errorf("InsertEntry(%d): %w", i, err)
+				return
+			}
+			if err := s.UpdateEntryFingerprint(id, fmt.Sprintf("fp%d", i)); err != nil {
+				errs <- fmt.Errorf("UpdateEntryFingerprint(%d): %w", i, err)
+				return
+			}
+			if err := s.InsertNote(models.Note{EntryID: id, Text: "note", Created: at(i)}); err != nil {
+				errs <- fmt.Errorf("InsertNote(%d): %w", i, err)
+				return
+			}
+			if err := s.SetSetting("last_poll", fmt.Sprint(i)); err != nil {
+				errs <- fmt.Errorf("SetSetting(%d): %w", i, err)
+				return
+			}
+			if _, err := s.GetEntries(); err != nil {
+				errs <- fmt.Errorf("GetEntries: %w", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	entries, err := s.GetEntries()
+	if err != nil {
+		t.Fatalf("GetEntries: %v", err)
+	}
+	if len(entries) != workers {
+		t.Errorf("GetEntries: got %d entries, want %d", len(entries), workers)
+	}
+	for _, e := range entries {
+		if want := fmt.Sprintf("fp%d", e.ID); e.Fingerprint != want {
+			t.Errorf("Entry %d: got fingerprint %q, want %q", e.ID, e.Fingerprint, want)
+		}
+	}
+
+	notes, err := s.GetNotes()
+	if err != nil {
+		t.Fatalf("GetNotes: %v", err)
+	}
+	ids := make(map[int64]bool)
+	for _, note := range notes {
+		ids[note.ID] = true
+	}
+	if len(notes) != workers || len(ids) != workers {
+		t.Errorf("GetNotes: got %d notes with %d unique IDs, want %d", len(notes), len(ids), workers)
+	}
+}
+
+func mustInsertEntries(t *testing.T, s store.Store, msgs ...models.Message) {
+	t.Helper()
+	for _, msg := range msgs {
+		if err := s.InsertEntry(msg); err != nil {
+			t.Fatalf("InsertEntry(%d): %v", msg.ID, err)
+		}
+	}
+}
+
+func assertEqual(t *testing.T, explanation string, got, want any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: got %+v, want %+v", explanation, got, want)
+	}
+}
+
+// normaliseMessage puts times in UTC, since backends hand them back in their own location
+func normaliseMessage(msg models.Message) models.Message {
+	msg.SentTime = msg.SentTime.UTC()
+	msg.UpdatedTime = msg.UpdatedTime.UTC()
+	return msg
+}
+
+func normalisePendingDelete(pending models.PendingDelete) models.PendingDelete {
+	pending.Entry = normaliseMessage(pending.Entry)
+	pending.Expires = pending.Expires.UTC()
+	return pending
+}
+
+func normaliseCallbackToken(token models.CallbackToken) models.CallbackToken {
+	token.Expires = token.Expires.UTC()
+	if token.EntryIDs == nil {
+		token.EntryIDs = []int64{}
+	}
+	return token
+}
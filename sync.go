@@ -0,0 +1,466 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"slices"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+const (
+	// How many entries to request from Miniflux at once when syncing
+	syncPageSize = 100
+	// Telegram only lets bots delete messages for 48 hours, after that
+	// read messages can't be cleaned up so we stop tracking them
+	messageDeleteWindow = 48 * time.Hour
+)
+
+// What the sync loop needs to do with a tracked message
+type syncKind int
+
+const (
+	syncDelete         syncKind = iota // The entry has been read so delete its message
+	syncCollapse                       // The entry has been read so collapse its message
+	syncUpdateKeyboard                 // The entry changed in Miniflux so update its keyboard
+	syncEditText                       // The entry's text changed in Miniflux so re-render its message
+	syncForget                         // The message is too old to delete so stop tracking it
+	syncGone                           // The entry is gone from Miniflux so its message is cleaned up
+	syncStrip                          // The message is about to become undeletable so remove its keyboard
+	syncFingerprint                    // The message was sent before fingerprints were stored so record the entry's
+)
+
+// allSyncKinds lists every kind of sync change, in the order they're reported
+var allSyncKinds = []syncKind{syncDelete, syncCollapse, syncUpdateKeyboard, syncEditText, syncForget, syncGone, syncStrip, syncFingerprint}
+
+func (k syncKind) String() string {
+	switch k {
+	case syncDelete:
+		return "deleted"
+	case syncCollapse:
+		return "collapsed"
+	case syncUpdateKeyboard:
+		return "keyboards_updated"
+	case syncEditText:
+		return "text_updated"
+	case syncForget:
+		return "too_old"
+	case syncGone:
+		return "gone"
+	case syncStrip:
+		return "stripped"
+	case syncFingerprint:
+		return "fingerprinted"
+	}
+	return "unknown"
+}
+
+// syncSummary counts what a sync did
+type syncSummary struct {
+	Checked  int              // How many tracked messages there were
+	Changes  map[syncKind]int // How many messages had each kind of change
+	Duration time.Duration    // How long the sync took
+}
+
+// logAttrs turns the summary into slog attributes
+func (s syncSummary) logAttrs() []any {
+	attrs := []any{"checked", s.Checked}
+	for _, kind := range allSyncKinds {
+		attrs = append(attrs, kind.String(), s.Changes[kind])
+	}
+	return append(attrs, "duration", s.Duration.Round(time.Millisecond))
+}
+
+// syncAction is a change the sync loop needs to make to a tracked message
+type syncAction struct {
+	kind    syncKind
+	message models.Message
+	entry   *miniflux.Entry // Nil for syncForget and syncGone
+}
+
+// fetchEntries gets the current state of a set of entries from Miniflux without asking for
+// them one at a time. Tracked entries are nearly all unread, starred or read since their
+// message was sent, so it pages through each of those within the tracked IDs, stopping
+// once everything is found. since is when the oldest message was sent, read entries
+// which changed before then are left for findGoneEntries to look up.
+func fetchEntries(rss *miniflux.Client, entryIDs []int64, since time.Time) (map[int64]*miniflux.Entry, error) {
+	entries := make(map[int64]*miniflux.Entry, len(entryIDs))
+	if len(entryIDs) == 0 {
+		return entries, nil
+	}
+
+	wanted := make(map[int64]bool, len(entryIDs))
+	for _, id := range entryIDs {
+		wanted[id] = true
+	}
+
+	filters := []*miniflux.Filter{
+		{Status: miniflux.EntryStatusUnread, Order: "id", Direction: "asc"},
+		{Starred: miniflux.FilterOnlyStarred, Order: "id", Direction: "asc"},
+		// Most recently changed first, so paging can stop at changes older than every message
+		{Status: miniflux.EntryStatusRead, Order: "changed_at", Direction: "desc"},
+	}
+	for _, filter := range filters {
+		filter.AfterEntryID = slices.Min(entryIDs) - 1
+		filter.BeforeEntryID = slices.Max(entryIDs) + 1
+		filter.Limit = syncPageSize
+		for offset := 0; len(entries) < len(wanted); offset += syncPageSize {
+			filter.Offset = offset
+			page, err := rss.Entries(filter)
+			if err != nil {
+				return entries, err
+			}
+			for _, entry := range page.Entries {
+				if wanted[entry.ID] {
+					entries[entry.ID] = entry
+				}
+			}
+			if len(page.Entries) < syncPageSize || offset+len(page.Entries) >= page.Total {
+				break
+			}
+			if filter.Order == "changed_at" && page.Entries[len(page.Entries)-1].ChangedAt.Before(since) {
+				break
+			}
+		}
+	}
+	return entries, nil
+}
+
+// findGoneEntries checks entries the bulk fetch didn't return one by one,
+// so only entries Miniflux says don't exist are treated as gone
+func findGoneEntries(rss *miniflux.Client, entryIDs []int64, entries map[int64]*miniflux.Entry) map[int64]bool {
+	gone := make(map[int64]bool)
+	for _, entryID := range entryIDs {
+		if _, ok := entries[entryID]; ok {
+			continue
+		}
+		entry, err := rss.Entry(entryID)
+		if isEntryGone(err) {
+			gone[entryID] = true
+		} else if err != nil {
+			slog.Error("Failed getting Miniflux entry", "error", err, "entry", entryID)
+			recordOutcome(outcomeMinifluxError)
+		} else {
+			entries[entryID] = entry
+		}
+	}
+	return gone
+}
+
+// planSync compares tracked messages with their entries in Miniflux and works
+// out which messages need changing. Messages whose entries weren't found, but
+// aren't known to be gone, are left alone.
+// Read messages that can no longer be deleted within margin get a final edit.
+// Pinned messages are the starred shelf, so they're never cleaned up once read.
+func planSync(messages []models.Message, entries map[int64]*miniflux.Entry, gone map[int64]bool, pinned map[int64]bool, policyFor func(categoryID int64) cleanupPolicy, margin time.Duration, now time.Time) []syncAction {
+	actions := make([]syncAction, 0)
+	for _, message := range messages {
+		if now.Sub(message.SentTime) >= messageDeleteWindow {
+			actions = append(actions, syncAction{kind: syncForget, message: message})
+			continue
+		}
+
+		entry, ok := entries[message.ID]
+		if gone[message.ID] || (ok && entry.Status == miniflux.EntryStatusRemoved) {
+			actions = append(actions, syncAction{kind: syncGone, message: message})
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if entry.Status == "read" && !pinned[message.ID] {
+			policy := policyFor(entryCategoryID(entry))
+			cleanup := policy.Read
+			if entry.Starred {
+				cleanup = policy.Starred
+			}
+			if message.DeleteRead && now.Sub(entry.ChangedAt) > policy.Grace {
+				switch cleanup {
+				case cleanupDelete:
+					actions = append(actions, syncAction{kind: syncDelete, message: message, entry: entry})
+					continue
+				case cleanupCollapse:
+					actions = append(actions, syncAction{kind: syncCollapse, message: message, entry: entry})
+					continue
+				}
+			}
+
+			// This is our last chance to tidy the message before we stop tracking it for good,
+			// including messages which were sent to be kept once read
+			if now.Sub(message.SentTime) >= messageDeleteWindow-margin {
+				switch policy.Expiring {
+				case expiringStrip:
+					actions = append(actions, syncAction{kind: syncStrip, message: message, entry: entry})
+					continue
+				case expiringCollapse:
+					actions = append(actions, syncAction{kind: syncCollapse, message: message, entry: entry})
+					continue
+				}
+			}
+		}
+
+		// Messages sent before fingerprints were stored are assumed to be current,
+		// rather than re-rendering every one of them in a burst after upgrading
+		if message.Fingerprint == "" {
+			actions = append(actions, syncAction{kind: syncFingerprint, message: message, entry: entry})
+		}
+
+		if message.Fingerprint != "" && entryFingerprint(entry) != message.Fingerprint {
+			// The title, feed or category has changed so the text is stale, this updates the keyboard too
+			actions = append(actions, syncAction{kind: syncEditText, message: message, entry: entry})
+		} else if entry.ChangedAt.Truncate(time.Second).After(message.UpdatedTime) {
+			// Miniflux stores times down to the millisecond which the bot doesn't,
+			// without truncating the entry is always seen as changed
+			actions = append(actions, syncAction{kind: syncUpdateKeyboard, message: message, entry: entry})
+		}
+	}
+	return actions
+}
+
+// entryCategoryID returns the ID of an entry's category, or 0 if it doesn't have one
+func entryCategoryID(entry *miniflux.Entry) int64 {
+	if entry.Feed == nil || entry.Feed.Category == nil {
+		return 0
+	}
+	return entry.Feed.Category.ID
+}
+
+// entryFingerprint hashes the text we render for an entry so we can tell when it changes
+func entryFingerprint(entry *miniflux.Entry) string {
+	sum := sha256.Sum256([]byte(formatEntry(entry)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// editEntryMessage re-renders an entry message's text and keyboard.
+// Entry messages are text, but if it turns out to have media we edit its caption instead.
+func editEntryMessage(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, store store.Store, messageID int, entry *miniflux.Entry) error {
+	keyboard := generateKeyboard(entry, secret, loadEntryExtras(store, entry.ID))
+
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, formatEntry(entry))
+	msg.ParseMode = "MarkdownV2"
+	msg.ReplyMarkup = &keyboard
+	_, err := bot.Send(msg)
+	if isNoText(err) {
+		caption := tgbotapi.NewEditMessageCaption(chatID, messageID, formatEntry(entry))
+		caption.ParseMode = "MarkdownV2"
+		caption.ReplyMarkup = &keyboard
+		_, err = bot.Send(caption)
+	}
+	if isNotModified(err) {
+		// The message already shows what we wanted
+		return nil
+	}
+	return err
+}
+
+// applySyncAction makes a planned change to a tracked message
+func applySyncAction(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, store store.Store, action syncAction) {
+	entry := action.message
+
+	// forget stops tracking the message, whatever happened to it
+	forget := func() {
+		if err := store.DeleteEntryByID(entry.ID); err != nil {
+			slog.Error("Error deleting entry in storage", "error", err)
+			recordOutcome(outcomeStorageError)
+		}
+	}
+	// unpin forgets any pin for a message that's been deleted
+	unpin := func() {
+		if err := store.DeletePin(entry.ID); err != nil {
+			slog.Error("Error deleting pin in storage", "error", err)
+			recordOutcome(outcomeStorageError)
+		}
+	}
+	// telegramFailed records a failed Telegram request, pruning the message if it's gone
+	telegramFailed := func(err error, msg string) {
+		if isMessageGone(err) {
+			slog.Info("Message is gone from Telegram, no longer tracking it", "entry", entry.ID)
+			recordOutcome(outcomeMessageGone)
+			forget()
+			return
+		}
+		slog.Error(msg, "error", err, "entry", entry.ID)
+		recordOutcome(outcomeTelegramError)
+	}
+
+	switch action.kind {
+	case syncDelete:
+		// If we're read past the grace period and set to delete it, cleanup the message
+		slog.Info("Deleting message for read entry", "entry", entry.ID)
+		if _, err := bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, entry.TelegramID)); err != nil {
+			// Keep tracking the message so the next run tries again, unless it's already gone
+			telegramFailed(err, "Failed deleting message in Telegram")
+			if isMessageGone(err) {
+				unpin()
+			}
+			return
+		}
+		recordOutcome(outcomeReadDeleted)
+		forget()
+		unpin()
+	case syncCollapse:
+		// Swap the message for a one line link and stop tracking it
+		slog.Info("Collapsing message for read entry", "entry", entry.ID)
+		if err := collapseMessage(bot, chatID, entry.TelegramID, action.entry); err != nil && !isNotModified(err) {
+			// Keep tracking the message so the next run tries again
+			telegramFailed(err, "Failed collapsing message in Telegram")
+			return
+		}
+		recordOutcome(outcomeReadCollapsed)
+		forget()
+	case syncEditText:
+		slog.Info("Updating message text for entry", "entry", entry.ID)
+		if err := editEntryMessage(bot, chatID, secret, store, entry.TelegramID, action.entry); err != nil {
+			telegramFailed(err, "Failed editing message in Telegram")
+			return
+		}
+		recordOutcome(outcomeTextUpdated)
+		if err := store.UpdateEntryFingerprint(entry.ID, entryFingerprint(action.entry)); err != nil {
+			slog.Error("Failed updating entry in storage", "error", err)
+			recordOutcome(outcomeStorageError)
+		}
+		if err := store.UpdateEntryTime(entry.ID, action.entry.ChangedAt); err != nil {
+			slog.Error("Failed updating entry in storage", "error", err)
+			recordOutcome(outcomeStorageError)
+		}
+	case syncUpdateKeyboard:
+		// If entry has been updated in Miniflux (marked as read, starred etc) update Telegram keyboard
+		slog.Info("Updating keyboard for entry", "entry", entry.ID)
+		if err := setKeyboard(bot, chatID, secret, store, entry.TelegramID, action.entry); err != nil && !isNotModified(err) {
+			telegramFailed(err, "Failed updating keyboard in Telegram")
+			return
+		}
+		recordOutcome(outcomeKeyboardUpdated)
+		if err := store.UpdateEntryTime(entry.ID, action.entry.ChangedAt); err != nil {
+			slog.Error("Failed updating entry in storage", "error", err)
+			recordOutcome(outcomeStorageError)
+		}
+	case syncStrip:
+		// The keyboard would go stale once we stop tracking the message, so remove it first
+		slog.Info("Removing keyboard from message about to become undeletable", "entry", entry.ID)
+		if err := stripKeyboard(bot, chatID, entry.TelegramID); err != nil && !isNotModified(err) {
+			// Keep tracking the message so the next run tries again
+			telegramFailed(err, "Failed removing keyboard in Telegram")
+			return
+		}
+		recordOutcome(outcomeExpiringStripped)
+		forget()
+	case syncFingerprint:
+		if err := store.UpdateEntryFingerprint(entry.ID, entryFingerprint(action.entry)); err != nil {
+			slog.Error("Failed updating entry in storage", "error", err)
+			recordOutcome(outcomeStorageError)
+		}
+	case syncForget:
+		// Cleanup the DB entry since there is nothing we can do with it
+		slog.Info("Cleaned up old entry in storage", "entry", entry.ID)
+		recordOutcome(outcomeTooOld)
+		forget()
+	case syncGone:
+		if cleanupGoneAction() == goneStub {
+			slog.Info("Entry is gone from Miniflux, replacing its message", "entry", entry.ID)
+			if _, err := bot.Send(tgbotapi.NewEditMessageText(chatID, entry.TelegramID, "This entry has been removed from Miniflux")); err != nil && !isNotModified(err) {
+				// Keep tracking the message so the next run tries again, unless it's already gone
+				telegramFailed(err, "Failed replacing message in Telegram")
+				return
+			}
+			recordOutcome(outcomeEntryGoneStubbed)
+		} else {
+			slog.Info("Entry is gone from Miniflux, deleting its message", "entry", entry.ID)
+			if _, err := bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, entry.TelegramID)); err != nil {
+				telegramFailed(err, "Failed deleting message in Telegram")
+				return
+			}
+			recordOutcome(outcomeEntryGoneDeleted)
+		}
+		// Any pin is left for the pin sync to remove, since a stubbed message is still pinned
+		forget()
+	}
+}
+
+// isCleanupKind checks whether a sync change removes or shrinks a message rather than keeping it up to date
+func isCleanupKind(kind syncKind) bool {
+	switch kind {
+	case syncDelete, syncCollapse, syncGone, syncStrip:
+		return true
+	}
+	return false
+}
+
+// withoutCleanup drops the actions which would clean up messages, for when cleanup is turned off
+func withoutCleanup(actions []syncAction) []syncAction {
+	kept := make([]syncAction, 0, len(actions))
+	for _, action := range actions {
+		if !isCleanupKind(action.kind) {
+			kept = append(kept, action)
+		}
+	}
+	return kept
+}
+
+// syncMessages checks every tracked message against Miniflux and makes any changes needed.
+// Messages are only cleaned up if cleanup is set, otherwise they're just kept up to date.
+func syncMessages(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, store store.Store, now time.Time, cleanup bool) syncSummary {
+	summary := syncSummary{Changes: make(map[syncKind]int)}
+	started := time.Now()
+
+	// Get all our entries
+	entries, err := store.GetEntries()
+	if err != nil {
+		slog.Error("Failed getting saved entries", "error", err)
+		recordOutcome(outcomeStorageError)
+	}
+	summary.Checked = len(entries)
+
+	// Fetch the entries whose messages we can still delete from Miniflux in bulk
+	entryIDs := make([]int64, 0, len(entries))
+	oldestSent := now
+	for _, entry := range entries {
+		if now.Sub(entry.SentTime) < messageDeleteWindow {
+			entryIDs = append(entryIDs, entry.ID)
+			if entry.SentTime.Before(oldestSent) {
+				oldestSent = entry.SentTime
+			}
+		}
+	}
+	minifluxEntries, err := fetchEntries(rss, entryIDs, oldestSent)
+	gone := make(map[int64]bool)
+	if err != nil {
+		slog.Error("Failed getting Miniflux entries", "error", err)
+		recordOutcome(outcomeMinifluxError)
+	} else {
+		// Only look for missing entries when we know the bulk fetch was complete
+		gone = findGoneEntries(rss, entryIDs, minifluxEntries)
+	}
+
+	pinned := make(map[int64]bool)
+	pins, err := store.GetPins()
+	if err != nil {
+		// Without the pins we can't tell which messages to keep, so leave cleanup until next time
+		slog.Error("Failed getting pins", "error", err)
+		recordOutcome(outcomeStorageError)
+		summary.Duration = time.Since(started)
+		return summary
+	}
+	for _, pin := range pins {
+		pinned[pin.EntryID] = true
+	}
+
+	actions := planSync(entries, minifluxEntries, gone, pinned, cleanupPolicyFor, expiringMargin(), now)
+	if !cleanup {
+		actions = withoutCleanup(actions)
+	}
+	for _, action := range actions {
+		applySyncAction(bot, chatID, secret, store, action)
+		summary.Changes[action.kind]++
+	}
+
+	summary.Duration = time.Since(started)
+	return summary
+}
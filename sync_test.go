@@ -0,0 +1,442 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store/memory"
+	miniflux "miniflux.app/client"
+)
+
+func TestPlanSync(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sent := now.Add(-6 * time.Hour)
+	defaultPolicy := cleanupPolicy{Grace: 2 * time.Hour, Read: cleanupDelete, Starred: cleanupDelete}
+	keepStarred := cleanupPolicy{Grace: 2 * time.Hour, Read: cleanupDelete, Starred: cleanupKeep}
+	collapse := cleanupPolicy{Grace: 30 * time.Minute, Read: cleanupCollapse, Starred: cleanupCollapse}
+	expiringStripped := cleanupPolicy{Grace: 2 * time.Hour, Read: cleanupKeep, Starred: cleanupKeep, Expiring: expiringStrip}
+	expiring := now.Add(-47*time.Hour - 30*time.Minute)
+
+	var tests = []struct {
+		message     models.Message
+		entry       *miniflux.Entry
+		policy      cleanupPolicy
+		expected    []syncKind
+		explanation string
+	}{
+		{
+			models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: true},
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-3 * time.Hour)},
+			defaultPolicy,
+			[]syncKind{syncDelete},
+			"Read for over 2 hours so the message is deleted",
+		},
+		{
+			models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: true},
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-time.Hour)},
+			defaultPolicy,
+			[]syncKind{syncUpdateKeyboard},
+			"Recently read so only the keyboard is updated",
+		},
+		{
+			models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: false},
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-3 * time.Hour)},
+			defaultPolicy,
+			[]syncKind{syncUpdateKeyboard},
+			"Messages not set to delete when read are kept",
+		},
+		{
+			models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: true},
+			&miniflux.Entry{ID: 1, Status: "unread", ChangedAt: sent.Add(500 * time.Millisecond)},
+			defaultPolicy,
+			[]syncKind{},
+			"Milliseconds are ignored when checking for changes",
+		},
+		{
+			models.Message{ID: 1, SentTime: now.Add(-49 * time.Hour), DeleteRead: true},
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-3 * time.Hour)},
+			defaultPolicy,
+			[]syncKind{syncForget},
+			"Messages too old to edit are forgotten",
+		},
+		{
+			models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: true},
+			nil,
+			defaultPolicy,
+			[]syncKind{},
+			"Entries missing from Miniflux are left alone",
+		},
+		{
+			models.Message{ID: 1, SentTime: sent, UpdatedTime: now.Add(-3 * time.Hour), DeleteRead: true},
+			&miniflux.Entry{ID: 1, Status: "read", Starred: true, ChangedAt: now.Add(-3 * time.Hour)},
+			keepStarred,
+			[]syncKind{},
+			"Starred entries can be kept",
+		},
+		{
+			models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: true},
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-time.Hour)},
+			collapse,
+			[]syncKind{syncCollapse},
+			"Messages can be collapsed after a shorter grace period",
+		},
+		{
+			models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: true, Fingerprint: "stale"},
+			&miniflux.Entry{ID: 1, Title: "Corrected title", Status: "unread", ChangedAt: now.Add(-time.Hour)},
+			defaultPolicy,
+			[]syncKind{syncEditText},
+			"Messages showing stale text are re-rendered",
+		},
+		{
+			models.Message{ID: 1, SentTime: expiring, UpdatedTime: expiring, DeleteRead: true},
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-3 * time.Hour)},
+			expiringStripped,
+			[]syncKind{syncStrip},
+			"Kept messages lose their keyboard before they become undeletable",
+		},
+		{
+			models.Message{ID: 1, SentTime: expiring, UpdatedTime: expiring, DeleteRead: true},
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-30 * time.Minute)},
+			builtinCleanupPolicy,
+			[]syncKind{syncCollapse},
+			"Recently read messages are collapsed before they become undeletable",
+		},
+		{
+			models.Message{ID: 1, SentTime: expiring, UpdatedTime: expiring, DeleteRead: true},
+			&miniflux.Entry{ID: 1, Status: "unread", ChangedAt: expiring},
+			builtinCleanupPolicy,
+			[]syncKind{},
+			"Unread messages keep their keyboard",
+		},
+		{
+			models.Message{ID: 1, SentTime: expiring, UpdatedTime: expiring},
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-3 * time.Hour)},
+			builtinCleanupPolicy,
+			[]syncKind{syncCollapse},
+			"Messages kept once read are still tidied before they become undeletable",
+		},
+		{
+			models.Message{ID: 1, SentTime: sent, UpdatedTime: sent},
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-3 * time.Hour)},
+			builtinCleanupPolicy,
+			[]syncKind{syncUpdateKeyboard},
+			"Messages kept once read aren't cleaned up after the grace period",
+		},
+	}
+
+	for _, tt := range tests {
+		entries := map[int64]*miniflux.Entry{}
+		if tt.entry != nil {
+			tt.entry.Feed = &miniflux.Feed{Title: "Hacker News", Category: &miniflux.Category{Title: "Tech"}}
+			if tt.message.Fingerprint == "" {
+				// Unless we're testing a change, the message shows the entry as it is now
+				tt.message.Fingerprint = entryFingerprint(tt.entry)
+			}
+			entries[tt.entry.ID] = tt.entry
+		}
+		actions := planSync([]models.Message{tt.message}, entries, nil, nil, func(int64) cleanupPolicy { return tt.policy }, time.Hour, now)
+		if len(actions) != len(tt.expected) {
+			t.Errorf("%s: got %d actions, want %d", tt.explanation, len(actions), len(tt.expected))
+			continue
+		}
+		for i, action := range actions {
+			if action.kind != tt.expected[i] {
+				t.Errorf("%s: got %v, want %v", tt.explanation, action.kind, tt.expected[i])
+			}
+		}
+	}
+}
+
+func TestPlanSyncUnknownFingerprint(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sent := now.Add(-6 * time.Hour)
+	policy := func(int64) cleanupPolicy { return builtinCleanupPolicy }
+	feed := &miniflux.Feed{Title: "Hacker News", Category: &miniflux.Category{Title: "Tech"}}
+
+	var tests = []struct {
+		entry       *miniflux.Entry
+		expected    []syncKind
+		explanation string
+	}{
+		{
+			&miniflux.Entry{ID: 1, Status: "unread", ChangedAt: sent, Feed: feed},
+			[]syncKind{syncFingerprint},
+			"Messages from before the upgrade have their fingerprint recorded without an edit",
+		},
+		{
+			&miniflux.Entry{ID: 1, Status: "read", ChangedAt: now.Add(-time.Hour), Feed: feed},
+			[]syncKind{syncFingerprint, syncUpdateKeyboard},
+			"Keyboards are still updated for entries that changed",
+		},
+	}
+
+	for _, tt := range tests {
+		message := models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: true}
+		actions := planSync([]models.Message{message}, map[int64]*miniflux.Entry{1: tt.entry}, nil, nil, policy, time.Hour, now)
+		kinds := make([]syncKind, 0, len(actions))
+		for _, action := range actions {
+			kinds = append(kinds, action.kind)
+		}
+		if !reflect.DeepEqual(kinds, tt.expected) {
+			t.Errorf("%s: got %v, want %v", tt.explanation, kinds, tt.expected)
+		}
+	}
+}
+
+func TestWithoutCleanup(t *testing.T) {
+	actions := make([]syncAction, 0, len(allSyncKinds))
+	for _, kind := range allSyncKinds {
+		actions = append(actions, syncAction{kind: kind})
+	}
+
+	var kinds []syncKind
+	for _, action := range withoutCleanup(actions) {
+		kinds = append(kinds, action.kind)
+	}
+	expected := []syncKind{syncUpdateKeyboard, syncEditText, syncForget, syncFingerprint}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("got %v, want %v", kinds, expected)
+	}
+}
+
+func TestPlanSyncPinned(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sent := now.Add(-6 * time.Hour)
+	expiring := now.Add(-47*time.Hour - 30*time.Minute)
+	policy := func(int64) cleanupPolicy { return builtinCleanupPolicy }
+	feed := &miniflux.Feed{Title: "Hacker News", Category: &miniflux.Category{Title: "Tech"}}
+
+	var tests = []struct {
+		message     models.Message
+		pinned      bool
+		expected    []syncKind
+		explanation string
+	}{
+		{models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: true}, false, []syncKind{syncDelete}, "Read starred entries are deleted by default"},
+		{models.Message{ID: 1, SentTime: sent, UpdatedTime: sent, DeleteRead: true}, true, []syncKind{syncUpdateKeyboard}, "Pinned messages stay once read"},
+		{models.Message{ID: 1, SentTime: expiring, UpdatedTime: expiring, DeleteRead: true}, true, []syncKind{syncUpdateKeyboard}, "Pinned messages keep their keyboard as they expire"},
+	}
+
+	for _, tt := range tests {
+		entry := &miniflux.Entry{ID: 1, Status: "read", Starred: true, ChangedAt: now.Add(-3 * time.Hour), Feed: feed}
+		tt.message.Fingerprint = entryFingerprint(entry)
+		actions := planSync([]models.Message{tt.message}, map[int64]*miniflux.Entry{1: entry}, nil, map[int64]bool{1: tt.pinned}, policy, time.Hour, now)
+		kinds := make([]syncKind, 0, len(actions))
+		for _, action := range actions {
+			kinds = append(kinds, action.kind)
+		}
+		if !reflect.DeepEqual(kinds, tt.expected) {
+			t.Errorf("%s: got %v, want %v", tt.explanation, kinds, tt.expected)
+		}
+	}
+}
+
+func TestPlanSyncGone(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sent := now.Add(-6 * time.Hour)
+	policy := func(int64) cleanupPolicy {
+		return cleanupPolicy{Grace: 2 * time.Hour, Read: cleanupDelete, Starred: cleanupDelete}
+	}
+
+	messages := []models.Message{
+		{ID: 1, SentTime: sent, UpdatedTime: sent},
+		{ID: 2, SentTime: sent, UpdatedTime: sent},
+		{ID: 3, SentTime: sent, UpdatedTime: sent},
+	}
+	entries := map[int64]*miniflux.Entry{
+		2: {ID: 2, Status: miniflux.EntryStatusRemoved, ChangedAt: sent},
+	}
+	gone := map[int64]bool{1: true}
+
+	actions := planSync(messages, entries, gone, nil, policy, time.Hour, now)
+	if len(actions) != 2 {
+		t.Fatalf("got %d actions, want 2", len(actions))
+	}
+	for i, action := range actions {
+		if action.kind != syncGone || action.message.ID != int64(i+1) {
+			t.Errorf("entry %d: got kind %v, want %v", action.message.ID, action.kind, syncGone)
+		}
+	}
+}
+
+func TestSyncSummaryLogAttrs(t *testing.T) {
+	summary := syncSummary{
+		Checked:  4,
+		Changes:  map[syncKind]int{syncDelete: 2, syncGone: 1},
+		Duration: 1500 * time.Microsecond,
+	}
+	attrs := summary.logAttrs()
+
+	expected := []any{
+		"checked", 4,
+		"deleted", 2,
+		"collapsed", 0,
+		"keyboards_updated", 0,
+		"text_updated", 0,
+		"too_old", 0,
+		"gone", 1,
+		"stripped", 0,
+		"fingerprinted", 0,
+		"duration", 2 * time.Millisecond,
+	}
+	if len(attrs) != len(expected) {
+		t.Fatalf("got %d attributes, want %d", len(attrs), len(expected))
+	}
+	for i := range expected {
+		if attrs[i] != expected[i] {
+			t.Errorf("attribute %d: got %v, want %v", i, attrs[i], expected[i])
+		}
+	}
+}
+
+func TestFetchEntriesBoundsRequests(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	longAgo := now.Add(-30 * 24 * time.Hour)
+
+	// Miniflux has an entry for every ID up to 100,000, all read long ago apart from the tracked ones
+	tracked := map[int64]*miniflux.Entry{
+		10:    {ID: 10, Status: "unread", ChangedAt: longAgo},
+		50000: {ID: 50000, Status: "unread", ChangedAt: longAgo},
+		50001: {ID: 50001, Status: "read", Starred: true, ChangedAt: longAgo},
+		50050: {ID: 50050, Status: "read", ChangedAt: now.Add(-time.Hour)},
+		70000: {ID: 70000, Status: "read", ChangedAt: longAgo},
+		99999: {ID: 99999, Status: "unread", ChangedAt: now.Add(-2 * time.Hour)},
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		query := r.URL.Query()
+		after, _ := strconv.ParseInt(query.Get("after_entry_id"), 10, 64)
+		before, _ := strconv.ParseInt(query.Get("before_entry_id"), 10, 64)
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		offset, _ := strconv.Atoi(query.Get("offset"))
+
+		matched := miniflux.Entries{}
+		for id := after + 1; id < before && id <= 100000; id++ {
+			entry, ok := tracked[id]
+			if !ok {
+				entry = &miniflux.Entry{ID: id, Status: "read", ChangedAt: longAgo}
+			}
+			if status := query.Get("status"); status != "" && entry.Status != status {
+				continue
+			}
+			if query.Get("starred") != "" && !entry.Starred {
+				continue
+			}
+			matched = append(matched, entry)
+		}
+		if query.Get("order") == "changed_at" {
+			sort.SliceStable(matched, func(i, j int) bool { return matched[i].ChangedAt.After(matched[j].ChangedAt) })
+		}
+
+		result := miniflux.EntryResultSet{Total: len(matched), Entries: miniflux.Entries{}}
+		if offset < len(matched) {
+			result.Entries = matched[offset:min(offset+limit, len(matched))]
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	entryIDs := []int64{10, 50000, 50001, 50050, 70000, 99999}
+	entries, err := fetchEntries(miniflux.New(server.URL, "key"), entryIDs, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("fetchEntries: %v", err)
+	}
+	for _, id := range []int64{10, 50000, 50001, 50050, 99999} {
+		if _, ok := entries[id]; !ok {
+			t.Errorf("Entry %d wasn't fetched", id)
+		}
+	}
+	if _, ok := entries[70000]; ok {
+		t.Error("Entry 70000 was read before any message was sent, so it's left for findGoneEntries")
+	}
+	if requests != 3 {
+		t.Errorf("Made %d requests, want one each for unread, starred and recently read entries", requests)
+	}
+}
+
+// roundTripFunc lets a function stand in for the Telegram API
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// fakeTelegram returns a bot whose requests fail with failure, or succeed if it's empty
+func fakeTelegram(t *testing.T, failure string) *tgbotapi.BotAPI {
+	t.Helper()
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"ok":true,"result":true}`
+		if path.Base(r.URL.Path) == "getMe" {
+			body = `{"ok":true,"result":{"id":1,"is_bot":true,"username":"miniflux_bot"}}`
+		} else if failure != "" {
+			body = `{"ok":false,"description":"` + failure + `"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	})}
+	bot, err := tgbotapi.NewBotAPIWithClient("token", client)
+	if err != nil {
+		t.Fatalf("creating fake bot: %v", err)
+	}
+	return bot
+}
+
+func TestApplySyncActionKeepsFailedMessages(t *testing.T) {
+	viper.Set("TELEGRAM_CLEANUP_GONE", goneDelete)
+	defer viper.Set("TELEGRAM_CLEANUP_GONE", nil)
+
+	var tests = []struct {
+		kind        syncKind
+		failure     string
+		tracked     bool
+		pinned      bool
+		explanation string
+	}{
+		{syncDelete, "", false, false, "Deleted messages are forgotten and unpinned"},
+		{syncDelete, "Too Many Requests: retry after 5", true, true, "Messages Telegram failed to delete are kept to try again"},
+		{syncDelete, "Bad Request: message to delete not found", false, false, "Messages already gone are forgotten and unpinned"},
+		{syncGone, "", false, true, "Messages for gone entries are forgotten once deleted"},
+		{syncGone, "Too Many Requests: retry after 5", true, true, "Messages for gone entries Telegram failed to delete are kept to try again"},
+	}
+
+	for _, tt := range tests {
+		s := memory.New()
+		message := models.Message{ID: 1, TelegramID: 10, SentTime: time.Now(), UpdatedTime: time.Now()}
+		if err := s.InsertEntry(message); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.InsertPin(models.Pin{EntryID: 1, TelegramID: 10, Pinned: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+
+		action := syncAction{kind: tt.kind, message: message}
+		if tt.kind == syncDelete {
+			action.entry = &miniflux.Entry{ID: 1, Status: "read"}
+		}
+		applySyncAction(fakeTelegram(t, tt.failure), 1, "secret", s, action)
+
+		_, err := s.GetEntry(1)
+		if tracked := err == nil; tracked != tt.tracked {
+			t.Errorf("%s: tracked is %v, want %v", tt.explanation, tracked, tt.tracked)
+		}
+		pins, err := s.GetPins()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pinned := len(pins) > 0; pinned != tt.pinned {
+			t.Errorf("%s: pinned is %v, want %v", tt.explanation, pinned, tt.pinned)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package telegraph
+
+import (
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Node is either a string of text or a NodeElement
+type Node interface{}
+
+// NodeElement is a HTML element in Telegraph's content format
+type NodeElement struct {
+	Tag      string            `json:"tag"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+	Children []Node            `json:"children,omitempty"`
+}
+
+// Tags Telegraph accepts, mapped from their HTML equivalents
+var allowedTags = map[string]string{
+	"a": "a", "aside": "aside", "b": "b", "strong": "strong", "blockquote": "blockquote",
+	"br": "br", "code": "code", "em": "em", "figcaption": "figcaption", "figure": "figure",
+	"h1": "h3", "h2": "h3", "h3": "h3", "h4": "h4", "h5": "h4", "h6": "h4",
+	"hr": "hr", "i": "i", "iframe": "iframe", "img": "img", "li": "li", "ol": "ol",
+	"p": "p", "pre": "pre", "s": "s", "del": "s", "u": "u", "ul": "ul", "video": "video",
+}
+
+// Tags whose content should never be published
+var skippedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "head": true, "template": true, "form": true,
+}
+
+// HTMLToNodes converts article HTML into Telegraph nodes. Unsupported tags are
+// unwrapped so their text is kept.
+func HTMLToNodes(content string) ([]Node, error) {
+	root, err := nethtml.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	return convertChildren(root), nil
+}
+
+func convertChildren(n *nethtml.Node) []Node {
+	nodes := make([]Node, 0)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		nodes = append(nodes, convert(child)...)
+	}
+	return nodes
+}
+
+func convert(n *nethtml.Node) []Node {
+	switch n.Type {
+	case nethtml.TextNode:
+		if strings.TrimSpace(n.Data) == "" && n.Parent != nil && n.Parent.Data != "pre" {
+			return nil
+		}
+		return []Node{n.Data}
+	case nethtml.ElementNode:
+		if skippedTags[n.Data] {
+			return nil
+		}
+		tag, ok := allowedTags[n.Data]
+		if !ok {
+			return convertChildren(n)
+		}
+		element := NodeElement{Tag: tag, Children: convertChildren(n)}
+		for _, attr := range n.Attr {
+			if attr.Key == "href" || attr.Key == "src" {
+				if element.Attrs == nil {
+					element.Attrs = make(map[string]string)
+				}
+				element.Attrs[attr.Key] = attr.Val
+			}
+		}
+		return []Node{element}
+	case nethtml.DocumentNode:
+		return convertChildren(n)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package telegraph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the base URL of the public Telegraph API
+const DefaultURL = "https://api.telegra.ph"
+
+// Telegraph rejects pages with more than 64KB of content
+const maxContentSize = 64 * 1024
+
+// Client talks to a Telegraph compatible API
+type Client struct {
+	BaseURL     string
+	AccessToken string
+	HTTPClient  *http.Client
+}
+
+// Account is a Telegraph account pages are published under
+type Account struct {
+	ShortName   string `json:"short_name"`
+	AuthorName  string `json:"author_name"`
+	AuthorURL   string `json:"author_url"`
+	AccessToken string `json:"access_token"`
+}
+
+// Page is a published Telegraph page
+type Page struct {
+	Path  string `json:"path"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+type response struct {
+	Ok     bool            `json:"ok"`
+	Error  string          `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+func New(baseURL string, accessToken string) *Client {
+	return &Client{
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+		AccessToken: accessToken,
+		HTTPClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// CreateAccount creates a new Telegraph account and stores its token on the client
+func (c *Client) CreateAccount(shortName string, authorName string) (Account, error) {
+	var account Account
+	err := c.call("createAccount", url.Values{
+		"short_name":  {shortName},
+		"author_name": {authorName},
+	}, &account)
+	if err != nil {
+		return account, err
+	}
+	c.AccessToken = account.AccessToken
+	return account, nil
+}
+
+// CreatePage publishes a new page. Content that is too large for Telegraph is truncated.
+func (c *Client) CreatePage(title string, authorName string, authorURL string, content []Node) (Page, error) {
+	var page Page
+	if c.AccessToken == "" {
+		return page, errors.New("telegraph: missing access token")
+	}
+
+	data, err := marshalContent(content)
+	if err != nil {
+		return page, err
+	}
+
+	err = c.call("createPage", url.Values{
+		"access_token": {c.AccessToken},
+		"title":        {truncate(title, 256)},
+		"author_name":  {truncate(authorName, 128)},
+		"author_url":   {truncate(authorURL, 512)},
+		"content":      {string(data)},
+	}, &page)
+	return page, err
+}
+
+func (c *Client) call(method string, params url.Values, result interface{}) error {
+	resp, err := c.HTTPClient.PostForm(c.BaseURL+"/"+method, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var apiResp response
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("telegraph: invalid response (%s): %w", resp.Status, err)
+	}
+	if !apiResp.Ok {
+		return fmt.Errorf("telegraph: %s failed: %s", method, apiResp.Error)
+	}
+	return json.Unmarshal(apiResp.Result, result)
+}
+
+// marshalContent encodes nodes, dropping trailing nodes until the content fits
+func marshalContent(content []Node) ([]byte, error) {
+	for {
+		data, err := json.Marshal(content)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) <= maxContentSize || len(content) <= 1 {
+			return data, nil
+		}
+		content = content[:len(content)-1]
+	}
+}
+
+func truncate(s string, limit int) string {
+	if runes := []rune(s); len(runes) > limit {
+		return string(runes[:limit])
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package telegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTMLToNodes(t *testing.T) {
+	var tests = []struct {
+		explanation string
+		input       string
+		expected    string
+	}{
+		{
+			"Paragraphs and formatting are kept",
+			"<p>Hello <b>world</b></p>",
+			`[{"tag":"p","children":["Hello ",{"tag":"b","children":["world"]}]}]`,
+		}, {
+			"Unsupported tags are unwrapped",
+			"<div><span>Text</span></div>",
+			`["Text"]`,
+		}, {
+			"Large headings are mapped to h3",
+			"<h1>Title</h1>",
+			`[{"tag":"h3","children":["Title"]}]`,
+		}, {
+			"Only href and src attributes are kept",
+			`<a href="https://example.com" class="link" onclick="x()">Link</a>`,
+			`[{"tag":"a","attrs":{"href":"https://example.com"},"children":["Link"]}]`,
+		}, {
+			"Scripts are removed",
+			"<p>Text</p><script>alert(1)</script>",
+			`[{"tag":"p","children":["Text"]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		nodes, err := HTMLToNodes(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.explanation, err)
+			continue
+		}
+		data, _ := json.Marshal(nodes)
+		if string(data) != tt.expected {
+			t.Errorf("%s: input [%s], got %s, want %s", tt.explanation, tt.input, data, tt.expected)
+		}
+	}
+}
+
+func TestCreatePage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		switch r.URL.Path {
+		case "/createAccount":
+			fmt.Fprintf(w, `{"ok":true,"result":{"short_name":%q,"access_token":"token"}}`, r.Form.Get("short_name"))
+		case "/createPage":
+			if r.Form.Get("access_token") != "token" {
+				fmt.Fprint(w, `{"ok":false,"error":"ACCESS_TOKEN_INVALID"}`)
+				return
+			}
+			fmt.Fprint(w, `{"ok":true,"result":{"path":"Title-01-01","url":"https://telegra.ph/Title-01-01","title":"Title"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL, "")
+	if _, err := client.CreatePage("Title", "", "", []Node{"Text"}); err == nil {
+		t.Error("expected error creating page without an access token")
+	}
+
+	account, err := client.CreateAccount("bot", "Bot")
+	if err != nil {
+		t.Fatalf("unexpected error creating account: %v", err)
+	}
+	if account.AccessToken != "token" || client.AccessToken != "token" {
+		t.Errorf("access token not set, got %q", client.AccessToken)
+	}
+
+	page, err := client.CreatePage("Title", "Author", "https://example.com", []Node{"Text"})
+	if err != nil {
+		t.Fatalf("unexpected error creating page: %v", err)
+	}
+	if page.Path != "Title-01-01" {
+		t.Errorf("got path %q, want Title-01-01", page.Path)
+	}
+
+	client.AccessToken = "invalid"
+	if _, err := client.CreatePage("Title", "", "", []Node{"Text"}); err == nil {
+		t.Error("expected error from API to be returned")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+)
+
+// How long token buttons keep working unless told otherwise
+const callbackTokenTTL = 7 * 24 * time.Hour
+
+var (
+	errCallbackTokenExpired   = errors.New("callback token has expired")
+	errCallbackTokenWrongChat = errors.New("callback token is for another chat")
+)
+
+// newCallbackToken saves a payload and returns callback data for a button that refers to it
+func newCallbackToken(store store.Store, secret types.TelegramSecret, payload models.CallbackToken) (string, error) {
+	payload, err := saveCallbackToken(store, payload)
+	if err != nil {
+		return "", err
+	}
+	return callbackData(secret, tokenAction, 0, payload.Token), nil
+}
+
+// saveCallbackToken gives a payload a random token and saves it
+func saveCallbackToken(store store.Store, payload models.CallbackToken) (models.CallbackToken, error) {
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return payload, err
+	}
+	payload.Token = base64.RawURLEncoding.EncodeToString(random)
+	if payload.Expires.IsZero() {
+		payload.Expires = time.Now().Add(callbackTokenTTL)
+	}
+	return payload, store.InsertCallbackToken(payload)
+}
+
+// resolveCallbackToken looks up the payload for a token button pressed in chatID.
+// Single use tokens are kept until consumeCallbackToken so a failed action can be retried.
+func resolveCallbackToken(store store.Store, token string, chatID int64, now time.Time) (models.CallbackToken, error) {
+	payload, err := store.GetCallbackToken(token)
+	if err != nil {
+		return payload, err
+	}
+	if now.After(payload.Expires) {
+		return payload, errCallbackTokenExpired
+	}
+	if payload.ChatID != chatID {
+		return payload, errCallbackTokenWrongChat
+	}
+	return payload, nil
+}
+
+// consumeCallbackToken removes a single use token once its action has worked
+func consumeCallbackToken(store store.Store, payload models.CallbackToken) error {
+	if !payload.SingleUse {
+		return nil
+	}
+	return store.DeleteCallbackToken(payload.Token)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/store/memory"
+)
+
+func TestResolveCallbackToken(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	const chatID = 12345
+
+	var tests = []struct {
+		token       models.CallbackToken
+		chatID      int64
+		err         error
+		kept        bool
+		explanation string
+	}{
+		{models.CallbackToken{Action: bulkRead, ChatID: chatID, Expires: now.Add(time.Hour)}, chatID, nil, true, "Reusable tokens are kept"},
+		{models.CallbackToken{Action: bulkRead, ChatID: chatID, SingleUse: true, Expires: now.Add(time.Hour)}, chatID, nil, true, "Single use tokens are kept until their action works"},
+		{models.CallbackToken{Action: bulkRead, ChatID: chatID, Expires: now}, chatID, nil, true, "Tokens still work at their expiry time"},
+		{models.CallbackToken{Action: bulkRead, ChatID: chatID, SingleUse: true, Expires: now.Add(-time.Second)}, chatID, errCallbackTokenExpired, true, "Expired tokens are rejected"},
+		{models.CallbackToken{Action: bulkRead, ChatID: chatID, SingleUse: true, Expires: now.Add(time.Hour)}, 999, errCallbackTokenWrongChat, true, "Tokens from another chat are rejected and kept"},
+	}
+
+	for _, tt := range tests {
+		s := memory.New()
+		tt.token.Token = "token"
+		if err := s.InsertCallbackToken(tt.token); err != nil {
+			t.Fatalf("%s: %v", tt.explanation, err)
+		}
+
+		payload, err := resolveCallbackToken(s, "token", tt.chatID, now)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got error %v, want %v", tt.explanation, err, tt.err)
+		}
+		if err == nil && payload.Action != tt.token.Action {
+			t.Errorf("%s: got action %q, want %q", tt.explanation, payload.Action, tt.token.Action)
+		}
+		if _, err := s.GetCallbackToken("token"); (err == nil) != tt.kept {
+			t.Errorf("%s: token kept is %v, want %v", tt.explanation, err == nil, tt.kept)
+		}
+	}
+
+	if _, err := resolveCallbackToken(memory.New(), "missing", chatID, now); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Missing tokens: got error %v, want ErrNotFound", err)
+	}
+}
+
+func TestConsumeCallbackToken(t *testing.T) {
+	var tests = []struct {
+		token       models.CallbackToken
+		kept        bool
+		explanation string
+	}{
+		{models.CallbackToken{Token: "token", SingleUse: true}, false, "Single use tokens are removed"},
+		{models.CallbackToken{Token: "token"}, true, "Reusable tokens are kept"},
+	}
+
+	for _, tt := range tests {
+		s := memory.New()
+		if err := s.InsertCallbackToken(tt.token); err != nil {
+			t.Fatalf("%s: %v", tt.explanation, err)
+		}
+		if err := consumeCallbackToken(s, tt.token); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.explanation, err)
+		}
+		if _, err := s.GetCallbackToken("token"); (err == nil) != tt.kept {
+			t.Errorf("%s: token kept is %v, want %v", tt.explanation, err == nil, tt.kept)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/spf13/viper"
+	"go.jloh.dev/miniflux-telegram-bot/models"
+	"go.jloh.dev/miniflux-telegram-bot/store"
+	"go.jloh.dev/miniflux-telegram-bot/types"
+	miniflux "miniflux.app/client"
+)
+
+// errUndoExpired is returned when undoing a delete whose window has already ended
+var errUndoExpired = errors.New("undo window has expired")
+
+// undoWindow is how long a deleted entry message can be restored for, zero deletes straight away
+func undoWindow() time.Duration {
+	return time.Duration(viper.GetInt64("TELEGRAM_UNDO_WINDOW")) * time.Second
+}
+
+// stubDeletedMessage replaces an entry message with an Undo button and moves
+// its stored entry aside so it can be restored until the window expires.
+// ErrNotFound is returned if the message isn't for an entry.
+func stubDeletedMessage(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, s store.Store, messageID int, markedRead bool, expires time.Time) error {
+	entry, err := s.GetEntryByTelegramID(messageID)
+	if err != nil {
+		return err
+	}
+
+	pending := models.PendingDelete{
+		Entry:      entry,
+		MarkedRead: markedRead,
+		Expires:    expires,
+	}
+	if err := s.InsertPendingDelete(pending); err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, "Deleted")
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Undo", callbackData(secret, undoDelete, entry.ID, "")),
+	))
+	msg.ReplyMarkup = &keyboard
+	if _, err := bot.Send(msg); err != nil {
+		s.DeletePendingDelete(messageID)
+		return err
+	}
+	return s.DeleteEntryByTelegramID(messageID)
+}
+
+// restoreDeletedMessage puts back an entry message that was replaced by an Undo stub
+func restoreDeletedMessage(bot *tgbotapi.BotAPI, chatID int64, secret types.TelegramSecret, rss *miniflux.Client, s store.Store, messageID int) error {
+	pending, err := s.GetPendingDelete(messageID)
+	if errors.Is(err, store.ErrNotFound) {
+		return errUndoExpired
+	} else if err != nil {
+		return err
+	}
+	// The stub is only removed once removeExpiredDeletes gets to it, so check the window ourselves
+	if time.Now().After(pending.Expires) {
+		return errUndoExpired
+	}
+
+	if pending.MarkedRead {
+		if err := rss.UpdateEntries([]int64{pending.Entry.ID}, "unread"); err != nil {
+			return err
+		}
+	}
+
+	entry, err := rss.Entry(pending.Entry.ID)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, formatEntry(entry))
+	msg.ParseMode = "MarkdownV2"
+	keyboard := generateKeyboard(entry, secret, loadEntryExtras(s, entry.ID))
+	msg.ReplyMarkup = &keyboard
+	if _, err := bot.Send(msg); err != nil {
+		return err
+	}
+
+	pending.Entry.Fingerprint = entryFingerprint(entry)
+	if err := s.InsertEntry(pending.Entry); err != nil {
+		return err
+	}
+	return s.DeletePendingDelete(messageID)
+}
+
+// removeExpiredDeletes deletes Undo stubs for good once their window has ended
+func removeExpiredDeletes(bot *tgbotapi.BotAPI, chatID int64, store store.Store) {
+	for {
+		expired, err := store.GetExpiredPendingDeletes(time.Now())
+		if err != nil {
+			slog.Error("Failed getting expired deletes", "error", err)
+		}
+
+		for _, pending := range expired {
+			if _, err := bot.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, pending.Entry.TelegramID)); err != nil {
+				slog.Error("Failed deleting message in Telegram", "error", err)
+			}
+			if err := store.DeletePendingDelete(pending.Entry.TelegramID); err != nil {
+				slog.Error("Failed deleting pending delete in storage", "error", err)
+			}
+			if err := store.DeletePin(pending.Entry.ID); err != nil {
+				slog.Error("Failed deleting pin in storage", "error", err)
+			}
+		}
+
+		time.Sleep(10 * time.Second)
+	}
+}